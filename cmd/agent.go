@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"github.com/whitfieldsdad/go-atomic-red-team/pkg/atomic"
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Run tests requested via the test invocation request directory",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		pollInterval, _ := flags.GetDuration("poll-interval")
+		if pollInterval <= 0 {
+			log.Fatalf("Invalid poll interval: %s (must be positive)", pollInterval)
+		}
+		password, _ := flags.GetString("password")
+		atomicsDir, plannedTests, err := planTests(password, flags)
+		if err != nil {
+			log.Fatalf("Failed to read tests: %s", err)
+		}
+		var tests []atomic.Test
+		for _, plannedTest := range plannedTests {
+			tests = append(tests, plannedTest.Test)
+		}
+		agent := atomic.NewAgent(atomicsDir, tests, atomic.GetDirectoriesFromEnv())
+		agent.PollInterval = pollInterval
+		if save, _ := flags.GetBool("save"); save {
+			agent.Runner.Store = atomic.NewResultStore(atomic.GetResultStoreDir())
+		}
+
+		ctx, stop := newInterruptibleContext()
+		defer stop()
+
+		err = agent.Run(ctx)
+		if err != nil {
+			log.Fatalf("Agent failed: %s", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(agentCmd)
+
+	// Only tests that are selected by these filters and plans may be requested.
+	agentCmd.Flags().StringP("atomics-dir", "", atomic.DefaultAtomicsDir, "Path to atomic-red-team/atomics directory")
+	agentCmd.Flags().StringP("password", "", "", "Password for decrypting atomics-dir")
+	agentCmd.Flags().AddFlagSet(newTestSelectionFlagset())
+	agentCmd.Flags().BoolP("save", "", false, "Save test results, including the output of commands, to the result store (see the results command)")
+	agentCmd.Flags().DurationP("poll-interval", "", atomic.DefaultAgentPollInterval, "How often to check for new test invocation requests")
+}
@@ -1,8 +1,16 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"github.com/whitfieldsdad/go-atomic-red-team/pkg/atomic"
+	"golang.org/x/term"
 )
 
 var archivesCmd = &cobra.Command{
@@ -14,18 +22,123 @@ var archivesCmd = &cobra.Command{
 var createArchiveCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a tarball",
-	Args:  cobra.MinimumNArgs(1),
+	Long:  "Create a tarball (.tar.gz) from an atomics directory, or an encrypted tarball (.tar.gz.age) if a password is provided. If any test filters or plans are provided, only the selected tests and the src and bin directories of their ATT&CK techniques are included.",
+	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		atomicsDir := getAtomicsDir(flags)
+		outputPath, _ := flags.GetString("output-path")
+		password, err := getArchivePassword(flags)
+		if err != nil {
+			log.Fatalf("Failed to read password: %s", err)
+		}
+		if password != "" && !strings.HasSuffix(outputPath, ".tar.gz.age") {
+			log.Warnf("Encrypted archives are only recognized if their name ends with .tar.gz.age")
+		} else if password == "" && !strings.HasSuffix(outputPath, ".tar.gz") {
+			log.Warnf("Archives are only recognized if their name ends with .tar.gz")
+		}
+
+		opts := &atomic.ArchiveOptions{Password: password}
+		if hasTestSelectionFlags(flags) {
+			var plannedTests []atomic.PlannedTest
+			atomicsDir, plannedTests, err = planTests("", flags)
+			if err != nil {
+				log.Fatalf("Failed to list tests: %s", err)
+			}
+			if len(plannedTests) == 0 {
+				log.Fatalf("No tests matched")
+			}
+			for _, plannedTest := range plannedTests {
+				opts.Tests = append(opts.Tests, plannedTest.Test)
+			}
+		}
 
+		f, err := os.Create(outputPath)
+		if err != nil {
+			log.Fatalf("Failed to create archive: %s", err)
+		}
+		err = atomic.CreateArchive(f, atomicsDir, opts)
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(outputPath)
+			log.Fatalf("Failed to create archive: %s", err)
+		}
+		if len(opts.Tests) > 0 {
+			log.Infof("Archived %d tests to %s", len(opts.Tests), outputPath)
+		} else {
+			log.Infof("Archived %s to %s", atomicsDir, outputPath)
+		}
 	},
 }
 
+// getArchivePassword returns the password for encrypting an archive. Passwords that are provided on the command line
+// can be seen by other users of the host, so the password can also be entered at a prompt, or provided using the
+// GO_ATOMIC_ARCHIVE_KEY environment variable.
+func getArchivePassword(flags *pflag.FlagSet) (string, error) {
+	if flags.Changed("password") {
+		return flags.GetString("password")
+	}
+	if prompt, _ := flags.GetBool("prompt-password"); prompt {
+		return promptPassword()
+	}
+	return os.Getenv(atomic.EnvArchiveKey), nil
+}
+
+// promptPassword reads a password from the terminal without echoing it. The password must be entered twice.
+func promptPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("standard input is not a terminal")
+	}
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+	password, err := read("Password: ")
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", errors.New("no password was entered")
+	}
+	confirmation, err := read("Confirm password: ")
+	if err != nil {
+		return "", err
+	}
+	if password != confirmation {
+		return "", errors.New("passwords don't match")
+	}
+	return password, nil
+}
+
+// hasTestSelectionFlags returns true if any flags that are used to select tests were provided.
+func hasTestSelectionFlags(flags *pflag.FlagSet) bool {
+	selected := false
+	newTestSelectionFlagset().VisitAll(func(flag *pflag.Flag) {
+		if flags.Changed(flag.Name) {
+			selected = true
+		}
+	})
+	return selected
+}
+
 func init() {
 	rootCmd.AddCommand(archivesCmd)
 	archivesCmd.AddCommand(createArchiveCmd)
 
 	flagset := pflag.FlagSet{}
 	flagset.StringP("output-path", "o", "", "Output path")
-	flagset.StringP("password", "p", "", "Password")
+	flagset.StringP("password", "", "", "Password for encrypting the archive (defaults to $"+atomic.EnvArchiveKey+")")
+	flagset.BoolP("prompt-password", "", false, "Prompt for a password for encrypting the archive")
+	flagset.StringP("atomics-dir", "", atomic.DefaultAtomicsDir, "Path to atomic-red-team/atomics directory")
+
+	flagset.AddFlagSet(newTestSelectionFlagset())
+
+	createArchiveCmd.Flags().AddFlagSet(&flagset)
 	_ = createArchiveCmd.MarkFlagRequired("output-path")
 }
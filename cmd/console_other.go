@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package cmd
+
+import "os"
+
+func getConsoleWidth(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+func getConsoleWidth(f *os.File) int {
+	var info windows.ConsoleScreenBufferInfo
+	err := windows.GetConsoleScreenBufferInfo(windows.Handle(f.Fd()), &info)
+	if err != nil {
+		return 0
+	}
+	return int(info.Window.Right-info.Window.Left) + 1
+}
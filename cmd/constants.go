@@ -9,6 +9,9 @@ const (
 	OutputFormatYaml  = "yaml"
 	OutputFormatPlain = "plain"
 	OutputFormatBrief = "brief"
+	OutputFormatJUnit = "junit"
+	OutputFormatTable = "table"
+	OutputFormatCSV   = "csv"
 )
 
 var (
@@ -1,6 +1,11 @@
 package cmd
 
-import "github.com/spf13/pflag"
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
 
 func getNullableBool(flag string, flags *pflag.FlagSet) (*bool, error) {
 	if flags.Changed(flag) {
@@ -12,3 +17,36 @@ func getNullableBool(flag string, flags *pflag.FlagSet) (*bool, error) {
 	}
 	return nil, nil
 }
+
+func getNullableFloat64(flag string, flags *pflag.FlagSet) (*float64, error) {
+	if flags.Changed(flag) {
+		val, err := flags.GetFloat64(flag)
+		if err != nil {
+			return nil, err
+		}
+		return &val, nil
+	}
+	return nil, nil
+}
+
+// getNullableTime reads a time from a flag. Times can be absolute (RFC 3339 or YYYY-MM-DD) or relative to now
+// (e.g. "72h" means 72 hours ago).
+func getNullableTime(flag string, flags *pflag.FlagSet) (*time.Time, error) {
+	if !flags.Changed(flag) {
+		return nil, nil
+	}
+	val, err := flags.GetString(flag)
+	if err != nil {
+		return nil, err
+	}
+	if d, err := time.ParseDuration(val); err == nil {
+		t := time.Now().Add(-d)
+		return &t, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, val, time.Local); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, errors.Errorf("invalid time for --%s: %s (expected a duration, RFC 3339 time, or YYYY-MM-DD)", flag, val)
+}
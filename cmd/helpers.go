@@ -1,8 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/charmbracelet/log"
 
 	"gopkg.in/yaml.v3"
 )
@@ -22,3 +28,22 @@ func PrintYaml(v interface{}) {
 	}
 	fmt.Println(string(blob))
 }
+
+// newInterruptibleContext returns a context that is cancelled when the process receives SIGINT or SIGTERM. After the
+// first signal, the default behaviour is restored, so a second Ctrl-C terminates the process immediately.
+func newInterruptibleContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			signal.Stop(signals)
+			log.Warnf("Interrupted - waiting for running tests to be cleaned up (press Ctrl-C again to exit immediately)")
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(signals)
+		}
+	}()
+	return ctx, cancel
+}
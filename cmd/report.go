@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/whitfieldsdad/go-atomic-red-team/pkg/atomic"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Generate reports from test results",
+	Long:  "Generate reports from test results. Results are read from files of exported results if --input is provided, and from the result store otherwise.",
+}
+
+var htmlReportCmd = &cobra.Command{
+	Use:   "html",
+	Short: "Write a self-contained HTML report",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		report := getReport(flags)
+		outputPath, _ := flags.GetString("output")
+		f, err := os.Create(outputPath)
+		if err != nil {
+			log.Fatalf("Failed to create output file: %s", err)
+		}
+		defer f.Close()
+		err = atomic.WriteHTMLReport(f, report)
+		if err != nil {
+			log.Fatalf("Failed to write HTML report: %s", err)
+		}
+		log.Infof("Wrote report of %d test results to %s", len(report.Results), outputPath)
+	},
+}
+
+var markdownReportCmd = &cobra.Command{
+	Use:     "markdown",
+	Aliases: []string{"md"},
+	Short:   "Write a Markdown summary (e.g. for tickets and pull requests)",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		report := getReport(flags)
+		w := os.Stdout
+		outputPath, _ := flags.GetString("output")
+		if outputPath != "" {
+			f, err := os.Create(outputPath)
+			if err != nil {
+				log.Fatalf("Failed to create output file: %s", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		err := atomic.WriteMarkdownReport(w, report)
+		if err != nil {
+			log.Fatalf("Failed to write Markdown report: %s", err)
+		}
+	},
+}
+
+func getReport(flags *pflag.FlagSet) *atomic.Report {
+	var results []atomic.TestResult
+	inputPaths, _ := flags.GetStringSlice("input")
+	if len(inputPaths) > 0 {
+		for _, path := range inputPaths {
+			r, err := atomic.ReadTestResults(path)
+			if err != nil {
+				log.Fatalf("Failed to read test results from %s: %s", path, err)
+			}
+			results = append(results, r...)
+		}
+	} else {
+		var err error
+		results, err = getResultStore(flags).Query(getResultQuery(flags))
+		if err != nil {
+			log.Fatalf("Failed to query test results: %s", err)
+		}
+	}
+	if len(results) == 0 {
+		log.Fatalf("No test results found")
+	}
+
+	var tactics map[string][]string
+	if atomicsDir := getAtomicsDir(flags); atomicsDir != "" {
+		var err error
+		tactics, err = atomic.ReadAttackTactics(atomicsDir)
+		if err != nil {
+			log.Warnf("Failed to read ATT&CK tactics from %s - techniques will be listed under an unknown tactic: %s", atomicsDir, err)
+		}
+	}
+	title, _ := flags.GetString("title")
+	return atomic.NewReport(title, results, tactics)
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.AddCommand(htmlReportCmd, markdownReportCmd)
+
+	reportCmd.PersistentFlags().StringSliceP("input", "i", []string{}, "Files of exported test results (JSON or JSON lines)")
+	reportCmd.PersistentFlags().StringP("store-dir", "", "", "Directory that test results are stored in (default: $"+atomic.EnvResultStoreDir+" or the user's config directory)")
+	reportCmd.PersistentFlags().StringP("atomics-dir", "", atomic.DefaultAtomicsDir, "Path to atomic-red-team/atomics directory (used to look up the tactics of each technique)")
+	reportCmd.PersistentFlags().StringP("title", "", "Atomic Red Team report", "Title of the report")
+	reportCmd.PersistentFlags().AddFlagSet(newResultQueryFlagset())
+
+	htmlReportCmd.Flags().StringP("output", "", "report.html", "Output file")
+	markdownReportCmd.Flags().StringP("output", "", "", "Write to this file instead of stdout")
+}
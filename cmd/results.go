@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/whitfieldsdad/go-atomic-red-team/pkg/atomic"
+)
+
+var resultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "Stored test results",
+}
+
+var listResultsCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"query"},
+	Short:   "List stored test results, most recent first",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		outputFormat, _ := flags.GetString("output-format")
+		results, err := getResultStore(flags).Query(getResultQuery(flags))
+		if err != nil {
+			log.Fatalf("Failed to query test results: %s", err)
+		}
+		if tmpl := getOutputTemplate(flags); tmpl != nil {
+			for _, result := range results {
+				mustPrintTemplate(tmpl, result)
+			}
+		} else if outputFormat == OutputFormatPlain {
+			for _, result := range results {
+				printTestResultBrief(result)
+			}
+		} else if outputFormat == OutputFormatJUnit {
+			err = atomic.WriteJUnitReport(os.Stdout, "Atomic Red Team", results)
+			if err != nil {
+				log.Fatalf("Failed to write JUnit report: %s", err)
+			}
+		} else if outputFormat == OutputFormatJson {
+			PrintJson(results)
+		} else if outputFormat == OutputFormatYaml {
+			PrintYaml(results)
+		} else {
+			log.Fatalf("Unknown output format: %s", outputFormat)
+		}
+	},
+}
+
+var showResultCmd = &cobra.Command{
+	Use:   "show <result-id>",
+	Short: "Show a stored test result",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		outputFormat, _ := flags.GetString("output-format")
+		store := getResultStore(flags)
+		result, err := store.GetResult(args[0])
+		if err != nil {
+			log.Fatalf("Failed to get test result: %s", err)
+		}
+		if tmpl := getOutputTemplate(flags); tmpl != nil {
+			mustPrintTemplate(tmpl, result)
+		} else {
+			printTestResult(*result, outputFormat)
+		}
+
+		showEvents, _ := flags.GetBool("events")
+		if showEvents && result.TestInvocationId != "" {
+			events, err := store.GetEvents(result.TestInvocationId)
+			if err != nil {
+				log.Fatalf("Failed to get events: %s", err)
+			}
+			for _, event := range events {
+				fmt.Println(string(event))
+			}
+		}
+	},
+}
+
+var exportResultsCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export stored test results as JSON lines",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		w := os.Stdout
+		outputPath, _ := flags.GetString("output")
+		if outputPath != "" {
+			f, err := os.Create(outputPath)
+			if err != nil {
+				log.Fatalf("Failed to create output file: %s", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		total, err := getResultStore(flags).Export(w, getResultQuery(flags))
+		if err != nil {
+			log.Fatalf("Failed to export test results: %s", err)
+		}
+		log.Infof("Exported %d test results", total)
+	},
+}
+
+var pruneResultsCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete stored test results",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		query := getResultQuery(flags)
+		olderThan, _ := flags.GetDuration("older-than")
+		if olderThan > 0 {
+			until := time.Now().Add(-olderThan)
+			query.Until = &until
+		}
+		all, _ := flags.GetBool("all")
+		if !all && olderThan == 0 && query.Until == nil {
+			log.Fatalf("Refusing to prune without --older-than, --until, or --all")
+		}
+		total, err := getResultStore(flags).Prune(query)
+		if err != nil {
+			log.Fatalf("Failed to prune test results: %s", err)
+		}
+		log.Infof("Deleted %d test results", total)
+	},
+}
+
+var listRunsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "List runs that have stored test results, most recent first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		outputFormat, _ := flags.GetString("output-format")
+		query := getResultQuery(flags)
+		runs, err := getResultStore(flags).ListRuns(query)
+		if err != nil {
+			log.Fatalf("Failed to list runs: %s", err)
+		}
+		if query.Limit > 0 && len(runs) > query.Limit {
+			runs = runs[:query.Limit]
+		}
+		if outputFormat == OutputFormatPlain {
+			for _, run := range runs {
+				s := run.Summary
+				fmt.Printf("%s  %s  %s  %d tests: %d passed, %d failed, %d skipped, %d errored, %d timed out\n", run.RunId, run.StartTime.Local().Format(time.DateTime), strings.Join(run.Hosts, ","), s.Total, s.Passed, s.Failed, s.Skipped, s.Errored, s.TimedOut)
+			}
+		} else if outputFormat == OutputFormatJson {
+			PrintJson(runs)
+		} else if outputFormat == OutputFormatYaml {
+			PrintYaml(runs)
+		} else {
+			log.Fatalf("Unknown output format: %s", outputFormat)
+		}
+	},
+}
+
+var diffResultsCmd = &cobra.Command{
+	Use:   "diff <run-a> <run-b>",
+	Short: "Compare the results of two runs",
+	Long:  "Compare the results of two runs. Each run can be a run ID (or a prefix of one), or a file of exported results.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		outputFormat, _ := flags.GetString("output-format")
+		store := getResultStore(flags)
+		resultsA, err := readRunResults(store, args[0])
+		if err != nil {
+			log.Fatalf("Failed to read results of %s: %s", args[0], err)
+		}
+		resultsB, err := readRunResults(store, args[1])
+		if err != nil {
+			log.Fatalf("Failed to read results of %s: %s", args[1], err)
+		}
+		diff := atomic.DiffTestResults(resultsA, resultsB)
+		showAll, _ := flags.GetBool("all")
+		if !showAll {
+			diff.Tests = diff.GetChangedTests()
+		}
+		if outputFormat == OutputFormatPlain {
+			printTestResultDiffPlain(diff)
+		} else if outputFormat == OutputFormatJson {
+			PrintJson(diff)
+		} else if outputFormat == OutputFormatYaml {
+			PrintYaml(diff)
+		} else {
+			log.Fatalf("Unknown output format: %s", outputFormat)
+		}
+		failOnRegression, _ := flags.GetBool("fail-on-regression")
+		if failOnRegression && diff.HasRegressions() {
+			setExitCode(1)
+		}
+	},
+}
+
+// readRunResults reads the results of a run from a file, if the provided path exists, or from the result store.
+func readRunResults(store *atomic.ResultStore, run string) ([]atomic.TestResult, error) {
+	if _, err := os.Stat(run); err == nil {
+		return atomic.ReadTestResults(run)
+	}
+	return store.GetRun(run)
+}
+
+func printTestResultDiffPlain(diff atomic.TestResultDiff) {
+	var changes []string
+	for _, change := range []string{atomic.TestChangeNewlyFailing, atomic.TestChangeNewlyPassing, atomic.TestChangeNewlySkipped, atomic.TestChangeStatusChanged, atomic.TestChangeChanged, atomic.TestChangeAdded, atomic.TestChangeRemoved, atomic.TestChangeUnchanged} {
+		if n := diff.Summary[change]; n > 0 {
+			changes = append(changes, fmt.Sprintf("%d %s", n, strings.ReplaceAll(change, "_", " ")))
+		}
+	}
+	fmt.Printf("Summary: %s\n", strings.Join(changes, ", "))
+	for _, test := range diff.Tests {
+		fmt.Println(lineSeparator)
+		fmt.Printf("%s: %s (%s)\n", test.AttackTechniqueId, test.Name, test.TestId)
+		if test.Hostname != "" {
+			fmt.Printf("Host: %s (%s)\n", test.Hostname, test.HostId)
+		} else if test.HostId != "" {
+			fmt.Printf("Host: %s\n", test.HostId)
+		}
+		fmt.Printf("Change: %s\n", strings.ReplaceAll(test.Change, "_", " "))
+		fmt.Printf("Status: %s -> %s\n", formatOptional(string(test.StatusA)), formatOptional(string(test.StatusB)))
+		if test.ExitCodeChanged() {
+			fmt.Printf("Exit code: %s -> %s\n", formatOptionalInt(test.ExitCodeA), formatOptionalInt(test.ExitCodeB))
+		}
+		if test.Change != atomic.TestChangeAdded && test.Change != atomic.TestChangeRemoved {
+			fmt.Printf("Duration: %s -> %s (%+.3fs)\n", test.DurationA.Round(time.Millisecond), test.DurationB.Round(time.Millisecond), test.DurationDelta.Seconds())
+		}
+		for _, path := range test.AddedExecutables {
+			fmt.Printf("+ %s\n", path)
+		}
+		for _, path := range test.RemovedExecutables {
+			fmt.Printf("- %s\n", path)
+		}
+	}
+}
+
+func formatOptional(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+func formatOptionalInt(i *int) string {
+	if i == nil {
+		return "(none)"
+	}
+	return fmt.Sprint(*i)
+}
+
+func getResultStore(flags *pflag.FlagSet) *atomic.ResultStore {
+	dir, _ := flags.GetString("store-dir")
+	if dir == "" {
+		dir = atomic.GetResultStoreDir()
+	}
+	return atomic.NewResultStore(dir)
+}
+
+func getResultQuery(flags *pflag.FlagSet) atomic.ResultQuery {
+	q := atomic.ResultQuery{}
+	q.TestIds, _ = flags.GetStringSlice("id")
+	q.AttackTechniqueIds, _ = flags.GetStringSlice("attack-technique-id")
+	q.Hosts, _ = flags.GetStringSlice("host")
+	q.RunIds, _ = flags.GetStringSlice("run-id")
+	statuses, _ := flags.GetStringSlice("status")
+	for _, status := range statuses {
+		q.Statuses = append(q.Statuses, atomic.TestStatus(status))
+	}
+	var err error
+	q.Since, err = getNullableTime("since", flags)
+	if err != nil {
+		log.Fatal(err)
+	}
+	q.Until, err = getNullableTime("until", flags)
+	if err != nil {
+		log.Fatal(err)
+	}
+	q.Latest, _ = flags.GetBool("latest")
+	q.Limit, _ = flags.GetInt("limit")
+	return q
+}
+
+func printTestResultBrief(result atomic.TestResult) {
+	host := result.HostId
+	if result.Host != nil {
+		host = result.Host.Hostname
+	}
+	fmt.Printf("%s  %s  %-9s  %s  %s (%s)\n", result.Id, result.Time.Local().Format(time.DateTime), result.Status, host, result.Test.GetDisplayName(), result.Test.AutoGeneratedGuid)
+}
+
+func init() {
+	rootCmd.AddCommand(resultsCmd)
+	resultsCmd.AddCommand(listResultsCmd, showResultCmd, exportResultsCmd, pruneResultsCmd, listRunsCmd, diffResultsCmd)
+
+	resultsCmd.PersistentFlags().StringP("store-dir", "", "", "Directory that test results are stored in (default: $"+atomic.EnvResultStoreDir+" or the user's config directory)")
+	resultsCmd.PersistentFlags().StringP("output-format", "o", OutputFormatPlain, "Output format")
+
+	queryFlagset := newResultQueryFlagset()
+	listResultsCmd.Flags().AddFlagSet(queryFlagset)
+	exportResultsCmd.Flags().AddFlagSet(queryFlagset)
+	pruneResultsCmd.Flags().AddFlagSet(queryFlagset)
+	listRunsCmd.Flags().AddFlagSet(queryFlagset)
+
+	templateFlagset := newTemplateFlagset()
+	listResultsCmd.Flags().AddFlagSet(templateFlagset)
+	showResultCmd.Flags().AddFlagSet(templateFlagset)
+
+	diffResultsCmd.Flags().BoolP("all", "", false, "Include tests that haven't changed")
+	diffResultsCmd.Flags().BoolP("fail-on-regression", "", false, "Exit with a non-zero status if any tests are newly failing")
+	showResultCmd.Flags().BoolP("events", "", false, "Also show the events of the test invocation")
+	exportResultsCmd.Flags().StringP("output", "", "", "Write to this file instead of stdout")
+	pruneResultsCmd.Flags().DurationP("older-than", "", 0, "Delete results that are older than this (e.g. 720h)")
+	pruneResultsCmd.Flags().BoolP("all", "", false, "Delete every matching result")
+}
+
+// newResultQueryFlagset returns the flags that are used to select stored test results (see getResultQuery).
+func newResultQueryFlagset() *pflag.FlagSet {
+	queryFlagset := &pflag.FlagSet{}
+	queryFlagset.StringSliceP("id", "", []string{}, "Test IDs")
+	queryFlagset.StringSliceP("attack-technique-id", "", []string{}, "ATT&CK technique IDs")
+	queryFlagset.StringSliceP("host", "", []string{}, "Host IDs or hostnames")
+	queryFlagset.StringSliceP("run-id", "", []string{}, "Run IDs")
+	queryFlagset.StringSliceP("status", "", []string{}, "Statuses (passed, failed, skipped, errored, timed_out)")
+	queryFlagset.StringP("since", "", "", "Only include results from on or after this time (e.g. 2024-01-01 or 168h)")
+	queryFlagset.StringP("until", "", "", "Only include results from on or before this time (e.g. 2024-01-01 or 168h)")
+	queryFlagset.BoolP("latest", "", false, "Only include the most recent result for each test on each host")
+	queryFlagset.IntP("limit", "n", 0, "Maximum number of results")
+	return queryFlagset
+}
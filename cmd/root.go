@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"os"
-
 	"github.com/spf13/cobra"
 )
 
@@ -11,13 +9,23 @@ var rootCmd = &cobra.Command{
 	Short: "",
 }
 
+// exitCode is the status that the process exits with once the command has completed. Commands set it instead of
+// calling os.Exit so that deferred functions (e.g. closing event sinks) are run.
+var exitCode int
+
+func setExitCode(code int) {
+	exitCode = code
+}
+
 func init() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 }
 
-func Execute() {
+// Execute runs the command and returns the status that the process should exit with.
+func Execute() int {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		return 1
 	}
+	return exitCode
 }
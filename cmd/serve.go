@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"github.com/whitfieldsdad/go-atomic-red-team/pkg/atomic"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an HTTP API for listing and running tests",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		password, _ := flags.GetString("password")
+		addr, _ := flags.GetString("listen")
+
+		server := atomic.NewServer(getAtomicsDir(flags), password)
+		server.Runner.Options = *getTestOptions(flags)
+		server.MaxCompletedInvocations, _ = flags.GetInt("max-completed-invocations")
+		if save, _ := flags.GetBool("save"); save {
+			server.Runner.Store = atomic.NewResultStore(atomic.GetResultStoreDir())
+		}
+
+		tlsOptions := &atomic.TLSOptions{}
+		tlsOptions.CertFile, _ = flags.GetString("tls-cert")
+		tlsOptions.KeyFile, _ = flags.GetString("tls-key")
+		tlsOptions.ClientCAFile, _ = flags.GetString("tls-client-ca")
+		tlsOptions.ClientCertPins, _ = flags.GetStringSlice("tls-client-cert-pin")
+		if tlsOptions.CertFile != "" || tlsOptions.KeyFile != "" {
+			server.TLS = tlsOptions
+		} else if tlsOptions.ClientCAFile != "" || len(tlsOptions.ClientCertPins) > 0 {
+			log.Fatalf("Client certificate verification requires --tls-cert and --tls-key")
+		}
+
+		var tokens []atomic.Token
+		tokensPath, _ := flags.GetString("tokens")
+		if tokensPath != "" {
+			var err error
+			tokens, err = atomic.ReadTokens(tokensPath)
+			if err != nil {
+				log.Fatalf("Failed to read tokens: %s", err)
+			}
+		}
+		auditLogPath, _ := flags.GetString("audit-log")
+		if len(tokens) > 0 || auditLogPath != "" {
+			server.Auth = atomic.NewAuthorizer(tokens, nil)
+			if auditLogPath != "" {
+				f, err := os.OpenFile(auditLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+				if err != nil {
+					log.Fatalf("Failed to open audit log: %s", err)
+				}
+				defer f.Close()
+				server.Auth.AuditLog = f
+			}
+		}
+		if len(tokens) == 0 && server.TLS == nil {
+			log.Warnf("No tokens or TLS have been configured - anyone who can connect to %s can run tests", addr)
+		}
+
+		ctx, stop := newInterruptibleContext()
+		defer stop()
+
+		log.Infof("Listening on %s", addr)
+		err := server.ListenAndServe(ctx, addr)
+		if err != nil {
+			log.Fatalf("Server failed: %s", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringP("atomics-dir", "", atomic.DefaultAtomicsDir, "Path to atomic-red-team/atomics directory")
+	serveCmd.Flags().StringP("password", "", "", "Password for decrypting atomics-dir")
+	serveCmd.Flags().StringP("listen", "l", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().DurationP("timeout", "", 0, "Maximum amount of time that each test command may run for (e.g. 30s, 5m)")
+	serveCmd.Flags().DurationP("dependency-timeout", "", 0, "Maximum amount of time that dependency resolution may take for each test")
+	serveCmd.Flags().IntP("max-completed-invocations", "", atomic.DefaultMaxCompletedInvocations, "Number of completed test invocations and results to keep in memory (0 keeps all of them)")
+	serveCmd.Flags().BoolP("save", "", false, "Save test results, including the output of commands, to the result store (see the results command)")
+	serveCmd.Flags().StringP("tls-cert", "", "", "Path to the server's TLS certificate")
+	serveCmd.Flags().StringP("tls-key", "", "", "Path to the server's TLS private key")
+	serveCmd.Flags().StringP("tls-client-ca", "", "", "Path to a CA certificate that client certificates must be issued by")
+	serveCmd.Flags().StringSliceP("tls-client-cert-pin", "", []string{}, "SHA-256 fingerprints of the client certificates that are allowed to connect")
+	serveCmd.Flags().StringP("tokens", "", "", "Path to a JSON or YAML file of bearer tokens and their scopes (list, run, run-elevated, run-with-options)")
+	serveCmd.Flags().StringP("audit-log", "", "", "Path to a file to append authorization decisions to (JSON lines)")
+	serveCmd.Flags().DurationP("cleanup-timeout", "", 0, "Maximum amount of time that each cleanup command may run for")
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/whitfieldsdad/go-atomic-red-team/pkg/atomic"
+)
+
+// testRow is a row of tabular output. Dependency is only set when listing dependencies.
+type testRow struct {
+	Test       atomic.Test
+	Dependency *atomic.Dependency
+}
+
+type column struct {
+	Name   string
+	Header string
+	Value  func(row testRow) string
+
+	// Truncate allows the column to be shortened so that tables fit the width of the terminal.
+	Truncate bool
+}
+
+var testColumns = []column{
+	{Name: "id", Header: "ID", Value: func(row testRow) string { return row.Test.AutoGeneratedGuid }},
+	{Name: "attack_technique_id", Header: "TECHNIQUE", Value: func(row testRow) string { return row.Test.AttackTechniqueId }},
+	{Name: "name", Header: "NAME", Value: func(row testRow) string { return row.Test.Name }, Truncate: true},
+	{Name: "platforms", Header: "PLATFORMS", Value: func(row testRow) string { return strings.Join(row.Test.SupportedPlatforms, ",") }},
+	{Name: "executor", Header: "EXECUTOR", Value: func(row testRow) string { return row.Test.Executor.Name }},
+	{Name: "elevation_required", Header: "ELEVATION", Value: func(row testRow) string { return strconv.FormatBool(row.Test.RequiresElevation()) }},
+	{Name: "dependencies", Header: "DEPENDENCIES", Value: func(row testRow) string { return strconv.Itoa(len(row.Test.Dependencies)) }},
+	{Name: "references_atomics_folder", Header: "ATOMICS FOLDER", Value: func(row testRow) string { return strconv.FormatBool(row.Test.HasReferencesToAtomicsFolder()) }},
+	{Name: "dependency", Header: "DEPENDENCY", Value: getDependencyDescription, Truncate: true},
+	{Name: "dependency_executor", Header: "DEPENDENCY EXECUTOR", Value: getDependencyExecutor},
+}
+
+var (
+	defaultTestColumns       = []string{"id", "attack_technique_id", "name", "platforms", "executor", "elevation_required", "dependencies", "references_atomics_folder"}
+	defaultDependencyColumns = []string{"id", "attack_technique_id", "name", "dependency", "dependency_executor"}
+)
+
+func getDependencyDescription(row testRow) string {
+	if row.Dependency == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(row.Dependency.Description), " ")
+}
+
+func getDependencyExecutor(row testRow) string {
+	if row.Dependency == nil {
+		return ""
+	}
+	if row.Dependency.ExecutorName != "" {
+		return row.Dependency.ExecutorName
+	}
+	return row.Test.DependencyExecutorName
+}
+
+func getColumns(names []string) ([]column, error) {
+	var columns []column
+	for _, name := range names {
+		i := slices.IndexFunc(testColumns, func(c column) bool {
+			return c.Name == name
+		})
+		if i < 0 {
+			var valid []string
+			for _, c := range testColumns {
+				valid = append(valid, c.Name)
+			}
+			return nil, fmt.Errorf("unknown column: %s (valid columns: %s)", name, strings.Join(valid, ", "))
+		}
+		columns = append(columns, testColumns[i])
+	}
+	return columns, nil
+}
+
+func isTabularOutputFormat(outputFormat string) bool {
+	return outputFormat == OutputFormatTable || outputFormat == OutputFormatCSV
+}
+
+func printTestRows(rows []testRow, columnNames []string, outputFormat string) error {
+	columns, err := getColumns(columnNames)
+	if err != nil {
+		return err
+	}
+	if outputFormat == OutputFormatCSV {
+		return writeCSV(os.Stdout, rows, columns)
+	}
+	return writeTable(os.Stdout, rows, columns, getTerminalWidth())
+}
+
+func writeCSV(w io.Writer, rows []testRow, columns []column) error {
+	writer := csv.NewWriter(w)
+	var record []string
+	for _, c := range columns {
+		record = append(record, c.Name)
+	}
+	err := writer.Write(record)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record = record[:0]
+		for _, c := range columns {
+			record = append(record, c.Value(row))
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+const tableColumnPadding = 2
+
+// writeTable writes rows as aligned columns. If a width is provided, truncatable columns are shortened until the
+// table fits.
+func writeTable(w io.Writer, rows []testRow, columns []column, width int) error {
+	cells := make([][]string, 0, len(rows)+1)
+	var header []string
+	for _, c := range columns {
+		header = append(header, c.Header)
+	}
+	cells = append(cells, header)
+	for _, row := range rows {
+		var values []string
+		for _, c := range columns {
+			values = append(values, c.Value(row))
+		}
+		cells = append(cells, values)
+	}
+	widths := make([]int, len(columns))
+	for _, values := range cells {
+		for i, value := range values {
+			widths[i] = max(widths[i], utf8.RuneCountInString(value))
+		}
+	}
+	if width > 0 {
+		fitColumns(widths, columns, width-tableColumnPadding*(len(columns)-1))
+	}
+
+	var sb strings.Builder
+	for _, values := range cells {
+		sb.Reset()
+		for i, value := range values {
+			value = truncate(value, widths[i])
+			sb.WriteString(value)
+			if i < len(values)-1 {
+				sb.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(value)+tableColumnPadding))
+			}
+		}
+		sb.WriteString("\n")
+		_, err := io.WriteString(w, sb.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fitColumns repeatedly shortens the widest truncatable column until the columns fit within the provided width, or
+// until the truncatable columns can't be shortened any further.
+func fitColumns(widths []int, columns []column, width int) {
+	const minWidth = 10
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	for total > width {
+		widest := -1
+		for i, c := range columns {
+			if c.Truncate && widths[i] > minWidth && (widest < 0 || widths[i] > widths[widest]) {
+				widest = i
+			}
+		}
+		if widest < 0 {
+			return
+		}
+		widths[widest]--
+		total--
+	}
+}
+
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
+
+// getTerminalWidth returns the width of the terminal that stdout is attached to, or 0 if stdout isn't a terminal. The
+// width can be overridden using the COLUMNS environment variable.
+func getTerminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return getConsoleWidth(os.Stdout)
+}
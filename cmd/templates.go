@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/pflag"
+	"github.com/whitfieldsdad/go-atomic-red-team/pkg/atomic"
+	"gopkg.in/yaml.v3"
+)
+
+// templateFuncs are the helper functions that are available to templates provided with --template or --template-file.
+var templateFuncs = template.FuncMap{
+	"join":  joinValues,
+	"split": strings.Split,
+	"trim":  strings.TrimSpace,
+	"indent": func(spaces int, s string) string {
+		padding := strings.Repeat(" ", spaces)
+		return padding + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n"+padding)
+	},
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"json": func(v interface{}) (string, error) {
+		blob, err := json.Marshal(v)
+		return string(blob), err
+	},
+	"yaml": func(v interface{}) (string, error) {
+		blob, err := yaml.Marshal(v)
+		return string(blob), err
+	},
+	"lookup":  lookup,
+	"default": func(fallback, v interface{}) interface{} { return defaultValue(fallback, v) },
+	"md5":     func(s string) string { return hashString(md5.New(), s) },
+	"sha1":    func(s string) string { return hashString(sha1.New(), s) },
+	"sha256":  func(s string) string { return hashString(sha256.New(), s) },
+}
+
+// testDependency is passed to templates when listing dependencies so that the test that each dependency belongs to
+// can be referenced (e.g. {{.Test.Name}}: {{.Description}}).
+type testDependency struct {
+	atomic.Dependency
+	Test atomic.Test
+}
+
+// getOutputTemplate returns the template provided with --template or --template-file, or nil if neither was
+// provided.
+func getOutputTemplate(flags *pflag.FlagSet) *template.Template {
+	text, _ := flags.GetString("template")
+	path, _ := flags.GetString("template-file")
+	if text != "" && path != "" {
+		log.Fatalf("Only one of --template and --template-file can be provided")
+	}
+	if path != "" {
+		blob, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read template: %s", err)
+		}
+		text = string(blob)
+	}
+	if text == "" {
+		return nil
+	}
+	tmpl, err := template.New("output").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		log.Fatalf("Failed to parse template: %s", err)
+	}
+	return tmpl
+}
+
+// printTemplate renders a value using the provided template. A newline is added unless the output already ends with
+// one.
+func printTemplate(tmpl *template.Template, v interface{}) error {
+	var sb strings.Builder
+	err := tmpl.Execute(&sb, v)
+	if err != nil {
+		return err
+	}
+	s := sb.String()
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	fmt.Print(s)
+	return nil
+}
+
+// mustPrintTemplate renders a value using the provided template, and exits if the template can't be rendered.
+func mustPrintTemplate(tmpl *template.Template, v interface{}) {
+	err := printTemplate(tmpl, v)
+	if err != nil {
+		log.Fatalf("Failed to render template: %s", err)
+	}
+}
+
+// checkTemplate renders a template against an empty value, so that mistakes in the template (e.g. references to fields
+// that don't exist) are reported before any tests are run, rather than when the first result is rendered. Other errors
+// may depend on the value being rendered (e.g. indexing into a list of commands), so they're only warned about. Errors
+// that occur while tests are running are logged rather than being fatal, so that running tests are still cleaned up.
+func checkTemplate(tmpl *template.Template, v interface{}) {
+	err := tmpl.Execute(io.Discard, v)
+	if err == nil {
+		return
+	}
+	if strings.Contains(err.Error(), "can't evaluate field") {
+		log.Fatalf("Invalid template: %s", err)
+	}
+	log.Warnf("Template could not be rendered for an empty result: %s", err)
+}
+
+// newEmptyTestResult returns a result for checking templates with. The identity is filled in, as it is for every
+// result, so that templates can reference the host and user (e.g. {{.Host.Hostname}}).
+func newEmptyTestResult() *atomic.TestResult {
+	return &atomic.TestResult{Identity: atomic.GetIdentity()}
+}
+
+func newTemplateFlagset() *pflag.FlagSet {
+	flagset := &pflag.FlagSet{}
+	flagset.StringP("template", "", "", "Go template to render each item with (e.g. '{{.AttackTechniqueId}} {{.Name}}')")
+	flagset.StringP("template-file", "", "", "Path to a file containing a Go template to render each item with")
+	return flagset
+}
+
+// joinValues joins the elements of a slice (e.g. []string, []int) using the provided separator.
+func joinValues(sep string, v interface{}) string {
+	if s, ok := v.([]string); ok {
+		return strings.Join(s, sep)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Sprint(v)
+	}
+	values := make([]string, rv.Len())
+	for i := range values {
+		values[i] = fmt.Sprint(rv.Index(i).Interface())
+	}
+	return strings.Join(values, sep)
+}
+
+// lookup returns the value of a key in a map (e.g. an input argument), or an empty string if the key doesn't exist.
+func lookup(key string, m interface{}) interface{} {
+	rv := reflect.ValueOf(m)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return ""
+	}
+	value := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))
+	if !value.IsValid() {
+		return ""
+	}
+	return value.Interface()
+}
+
+func defaultValue(fallback, v interface{}) interface{} {
+	if v == nil {
+		return fallback
+	}
+	rv := reflect.ValueOf(v)
+	if rv.IsZero() {
+		return fallback
+	}
+	return v
+}
+
+func hashString(h hash.Hash, s string) string {
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
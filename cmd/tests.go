@@ -1,8 +1,8 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
+	"os"
 	"runtime"
 	"slices"
 	"strings"
@@ -31,8 +31,29 @@ var listTestsCmd = &cobra.Command{
 			log.Errorf("Failed to list tests: %s", err)
 			return
 		}
-		for _, test := range tests {
-			printTest(test, outputFormat)
+		if tmpl := getOutputTemplate(flags); tmpl != nil {
+			for _, test := range tests {
+				mustPrintTemplate(tmpl, test)
+			}
+		} else if isTabularOutputFormat(outputFormat) {
+			var rows []testRow
+			for _, test := range tests {
+				rows = append(rows, testRow{Test: test})
+			}
+			printTestRowsWithFlags(rows, defaultTestColumns, outputFormat, flags)
+		} else {
+			for _, test := range tests {
+				printTest(test, outputFormat)
+			}
+		}
+		layerOutputPath, _ := flags.GetString("layer-output")
+		if layerOutputPath != "" {
+			layerName, _ := flags.GetString("layer-name")
+			layer := atomic.NewNavigatorLayerFromTests(layerName, tests)
+			err = atomic.WriteNavigatorLayer(layerOutputPath, *layer)
+			if err != nil {
+				log.Errorf("Failed to write ATT&CK Navigator layer: %s", err)
+			}
 		}
 	},
 }
@@ -58,26 +79,155 @@ var executeTestsCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		flags := cmd.Flags()
 		outputFormat, _ := flags.GetString("output-format")
-		atomicsDir := getAtomicsDir(flags)
 		opts := getTestOptions(flags)
+		tmpl := getOutputTemplate(flags)
 
-		tests, err := listTests(flags)
+		password, _ := flags.GetString("password")
+		atomicsDir, plannedTests, err := planTests(password, flags)
 		if err != nil {
 			log.Errorf("Failed to list tests: %s", err)
 			return
 		}
-		ctx := context.Background()
+		dryRun, _ := flags.GetBool("dry-run")
+		if tmpl != nil && dryRun {
+			checkTemplate(tmpl, &atomic.DryRunResult{})
+		} else if tmpl != nil {
+			checkTemplate(tmpl, newEmptyTestResult())
+		}
+		if dryRun {
+			failed := 0
+			for _, plannedTest := range plannedTests {
+				test := plannedTest.Test
+				result, err := test.DryRun(atomicsDir, atomic.MergeTestOptions(plannedTest.Options, *opts))
+				if err != nil {
+					log.Errorf("Failed to prepare test '%s': %s", test.GetDisplayName(), err)
+					failed++
+					continue
+				}
+				if tmpl != nil {
+					mustPrintTemplate(tmpl, result)
+				} else {
+					printDryRunResult(*result, outputFormat)
+				}
+			}
+			if failed > 0 {
+				log.Errorf("Failed to prepare %d/%d tests", failed, len(plannedTests))
+				setExitCode(1)
+			}
+			return
+		}
+		failurePolicyName, _ := flags.GetString("failure-policy")
+		failurePolicy, err := atomic.ParseFailurePolicy(failurePolicyName)
+		if err != nil {
+			log.Fatalf("Failed to parse failure policy: %s", err)
+		}
+		workers, _ := flags.GetInt("workers")
+		runner := atomic.NewRunner(atomicsDir, workers)
+		runner.Options = *opts
+		runner.FailurePolicy = *failurePolicy
+
+		var sinks atomic.MultiEventSink
+		eventsPath, _ := flags.GetString("events")
+		if eventsPath != "" {
+			f, err := os.Create(eventsPath)
+			if err != nil {
+				log.Fatalf("Failed to create events file: %s", err)
+			}
+			defer f.Close()
+			sinks = append(sinks, atomic.NewJSONLinesEventSink(f))
+		}
+		writeToDirs, _ := flags.GetBool("write-to-dirs")
+		if writeToDirs {
+			sinks = append(sinks, atomic.NewDirectoryEventSink(atomic.GetDirectoriesFromEnv()))
+		}
+		if len(sinks) > 0 {
+			runner.Events = sinks
+		}
+		if save, _ := flags.GetBool("save"); save {
+			runner.Store = atomic.NewResultStore(atomic.GetResultStoreDir())
+		}
+
+		ctx, cancel := newInterruptibleContext()
+		defer cancel()
+
 		var results []atomic.TestResult
-		for _, test := range tests {
-			result, err := test.Run(ctx, atomicsDir, opts)
+		for result := range runner.Run(ctx, plannedTests) {
+			if tmpl != nil {
+				err = printTemplate(tmpl, result.Result)
+				if err != nil {
+					log.Errorf("Failed to render template: %s", err)
+				}
+			} else if outputFormat != OutputFormatJUnit {
+				printTestResult(*result.Result, outputFormat)
+			}
+			results = append(results, *result.Result)
+		}
+		if tmpl == nil && outputFormat == OutputFormatJUnit {
+			err = atomic.WriteJUnitReport(os.Stdout, "Atomic Red Team", results)
 			if err != nil {
-				log.Fatalf("Failed to execute test '%s': %s", test.GetDisplayName(), err)
+				log.Errorf("Failed to write JUnit report: %s", err)
 			}
-			results = append(results, *result)
 		}
-		for _, result := range results {
-			printTestResult(result, outputFormat)
+		layerOutputPath, _ := flags.GetString("layer-output")
+		if layerOutputPath != "" {
+			layerName, _ := flags.GetString("layer-name")
+			layer := atomic.NewNavigatorLayerFromTestResults(layerName, results)
+			err = atomic.WriteNavigatorLayer(layerOutputPath, *layer)
+			if err != nil {
+				log.Errorf("Failed to write ATT&CK Navigator layer: %s", err)
+			}
 		}
+		summary := atomic.SummarizeTestResults(results)
+		log.Infof("Ran %d tests: %d passed, %d failed, %d skipped, %d errored, %d timed out", summary.Total, summary.Passed, summary.Failed, summary.Skipped, summary.Errored, summary.TimedOut)
+		setExitCode(summary.ExitCode())
+	},
+}
+
+var cleanupTestsCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Run the cleanup commands of tests",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		outputFormat, _ := flags.GetString("output-format")
+		opts := getTestOptions(flags)
+		tmpl := getOutputTemplate(flags)
+		if tmpl != nil {
+			checkTemplate(tmpl, newEmptyTestResult())
+		}
+
+		password, _ := flags.GetString("password")
+		atomicsDir, plannedTests, err := planTests(password, flags)
+		if err != nil {
+			log.Errorf("Failed to list tests: %s", err)
+			return
+		}
+		ctx, cancel := newInterruptibleContext()
+		defer cancel()
+
+		var results []atomic.TestResult
+		for _, plannedTest := range plannedTests {
+			test := plannedTest.Test
+			if test.Executor.CleanupCommand == "" {
+				continue
+			}
+			result, err := test.Cleanup(ctx, atomicsDir, atomic.MergeTestOptions(plannedTest.Options, *opts))
+			if err != nil {
+				log.Errorf("Failed to clean up test '%s': %s", test.GetDisplayName(), err)
+				result = atomic.NewTestResultFromError(test, err)
+			}
+			if tmpl != nil {
+				err = printTemplate(tmpl, result)
+				if err != nil {
+					log.Errorf("Failed to render template: %s", err)
+				}
+			} else {
+				printTestResult(*result, outputFormat)
+			}
+			results = append(results, *result)
+		}
+		summary := atomic.SummarizeTestResults(results)
+		setExitCode(summary.ExitCode())
 	},
 }
 
@@ -98,6 +248,24 @@ var listDependenciesCmd = &cobra.Command{
 			log.Errorf("Failed to list tests: %s", err)
 			return
 		}
+		if tmpl := getOutputTemplate(flags); tmpl != nil {
+			for _, test := range tests {
+				for _, dependency := range test.Dependencies {
+					mustPrintTemplate(tmpl, testDependency{Dependency: dependency, Test: test})
+				}
+			}
+			return
+		}
+		if isTabularOutputFormat(outputFormat) {
+			var rows []testRow
+			for _, test := range tests {
+				for i := range test.Dependencies {
+					rows = append(rows, testRow{Test: test, Dependency: &test.Dependencies[i]})
+				}
+			}
+			printTestRowsWithFlags(rows, defaultDependencyColumns, outputFormat, flags)
+			return
+		}
 		for _, test := range tests {
 			for _, dependency := range test.Dependencies {
 				printTestDependency(test, dependency, outputFormat)
@@ -139,22 +307,109 @@ var countDependenciesCmd = &cobra.Command{
 	},
 }
 
+var advertiseTestsCmd = &cobra.Command{
+	Use:   "advertise",
+	Short: "Check which tests can be run on this host",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		outputFormat, _ := flags.GetString("output-format")
+		password, _ := flags.GetString("password")
+		atomicsDir, plannedTests, err := planTests(password, flags)
+		if err != nil {
+			log.Fatalf("Failed to list tests: %s", err)
+		}
+		var tests []atomic.Test
+		for _, plannedTest := range plannedTests {
+			tests = append(tests, plannedTest.Test)
+		}
+		opts := &atomic.AdvertisementOptions{}
+		opts.CheckDependencies, _ = flags.GetBool("check-dependencies")
+		opts.DependencyTimeout, _ = flags.GetDuration("dependency-timeout")
+
+		ctx, stop := newInterruptibleContext()
+		defer stop()
+
+		advertisement, err := atomic.AdvertiseTests(ctx, atomicsDir, tests, atomic.GetIdentity(), opts)
+		if err != nil {
+			log.Fatalf("Failed to check tests: %s", err)
+		}
+		runnableOnly, _ := flags.GetBool("runnable-only")
+		if runnableOnly {
+			advertisement.Tests = slices.DeleteFunc(advertisement.Tests, func(test atomic.AdvertisedTest) bool {
+				return !test.Runnable
+			})
+		}
+		printTestAdvertisement(*advertisement, outputFormat)
+	},
+}
+
 func listTests(flags *pflag.FlagSet) ([]atomic.Test, error) {
-	atomicsDir, _ := flags.GetString("atomics-dir")
 	password, _ := flags.GetString("password")
+	_, plannedTests, err := planTests(password, flags)
+	if err != nil {
+		return nil, err
+	}
+	var tests []atomic.Test
+	for _, plannedTest := range plannedTests {
+		tests = append(tests, plannedTest.Test)
+	}
+	return tests, nil
+}
 
-	var filter *atomic.TestFilter
-	commandLineFilter := getCommandLineFilter(flags)
-	var testPlanFilter *atomic.TestFilter
+// planTests reads the tests in an atomics directory or archive, selects tests using the filter, plan, and layer flags,
+// and returns the path to the atomics directory that they were read from.
+//
+// The atomics directory specified by the test plans is used unless the --atomics-dir flag was provided.
+func planTests(password string, flags *pflag.FlagSet) (string, []atomic.PlannedTest, error) {
+	planPaths, _ := flags.GetStringSlice("plan")
 
-	if commandLineFilter != nil && testPlanFilter != nil {
-		filter = atomic.MergeTestFilters(*commandLineFilter, *testPlanFilter)
-	} else if commandLineFilter != nil {
-		filter = commandLineFilter
-	} else if testPlanFilter != nil {
-		filter = testPlanFilter
+	plans, err := atomic.ReadTestPlans(planPaths)
+	if err != nil {
+		return "", nil, err
+	}
+	for i, plan := range plans {
+		if layerPlan, ok := plan.(atomic.NavigatorLayerPlan); ok {
+			plans[i] = applyNavigatorLayerFlags(layerPlan, flags)
+		}
 	}
-	return atomic.ReadTests(atomicsDir, password, filter)
+	atomicsDir := getAtomicsDir(flags)
+	if !flags.Changed("atomics-dir") {
+		planAtomicsDir, err := atomic.GetTestPlanAtomicsDir(plans...)
+		if err != nil {
+			return "", nil, err
+		}
+		if planAtomicsDir != "" {
+			atomicsDir = planAtomicsDir
+		}
+	}
+	tests, err := atomic.ReadTests(atomicsDir, password, getCommandLineFilter(flags))
+	if err != nil {
+		return "", nil, err
+	}
+	plannedTests, err := atomic.SelectTests(tests, plans...)
+	if err != nil {
+		return "", nil, err
+	}
+	return atomicsDir, plannedTests, nil
+}
+
+// applyNavigatorLayerFlags overrides the thresholds of an ATT&CK Navigator layer plan with any layer flags that were
+// provided.
+func applyNavigatorLayerFlags(plan atomic.NavigatorLayerPlan, flags *pflag.FlagSet) atomic.NavigatorLayerPlan {
+	if flags.Changed("layer-min-score") {
+		plan.MinimumScore, _ = getNullableFloat64("layer-min-score", flags)
+	}
+	if flags.Changed("layer-max-score") {
+		plan.MaximumScore, _ = getNullableFloat64("layer-max-score", flags)
+	}
+	if flags.Changed("layer-color") {
+		plan.Colors, _ = flags.GetStringSlice("layer-color")
+	}
+	if flags.Changed("layer-tactic") {
+		plan.Tactics, _ = flags.GetStringSlice("layer-tactic")
+	}
+	return plan
 }
 
 func getAtomicsDir(flags *pflag.FlagSet) string {
@@ -167,9 +422,28 @@ func getAtomicsDir(flags *pflag.FlagSet) string {
 
 func getTestOptions(flags *pflag.FlagSet) *atomic.TestOptions {
 	opts := atomic.NewTestOptions()
+	timeout, _ := flags.GetDuration("timeout")
+	opts.Timeout = timeout.Seconds()
+	dependencyTimeout, _ := flags.GetDuration("dependency-timeout")
+	opts.DependencyTimeout = dependencyTimeout.Seconds()
+	cleanupTimeout, _ := flags.GetDuration("cleanup-timeout")
+	opts.CleanupTimeout = cleanupTimeout.Seconds()
 	return opts
 }
 
+// printTestRowsWithFlags prints rows as a table or CSV using the columns selected by the --columns flag, or the
+// provided default columns.
+func printTestRowsWithFlags(rows []testRow, defaultColumns []string, outputFormat string, flags *pflag.FlagSet) {
+	columns, _ := flags.GetStringSlice("columns")
+	if len(columns) == 0 {
+		columns = defaultColumns
+	}
+	err := printTestRows(rows, columns, outputFormat)
+	if err != nil {
+		log.Fatalf("Failed to print %s: %s", outputFormat, err)
+	}
+}
+
 func getCommandLineFilter(flags *pflag.FlagSet) *atomic.TestFilter {
 	f := &atomic.TestFilter{}
 	f.Ids, _ = flags.GetStringSlice("id")
@@ -186,6 +460,36 @@ func getCommandLineFilter(flags *pflag.FlagSet) *atomic.TestFilter {
 	return f
 }
 
+func printTestAdvertisement(advertisement atomic.TestAdvertisement, outputFormat string) {
+	if outputFormat == OutputFormatPlain {
+		fmt.Printf("Agent ID: %s\n", advertisement.AgentId)
+		fmt.Printf("Host ID: %s\n", advertisement.HostId)
+		fmt.Printf("Platform: %s\n", advertisement.Platform)
+		fmt.Printf("Elevated: %t\n", advertisement.Elevated)
+		if !advertisement.DependenciesChecked {
+			fmt.Println("Dependencies: not checked (use --check-dependencies to check them)")
+		}
+		fmt.Printf("Runnable tests: %d/%d\n", len(advertisement.GetRunnableTestIds()), len(advertisement.Tests))
+		fmt.Println(lineSeparator)
+		for _, test := range advertisement.Tests {
+			fmt.Printf("%s (%s): ", test.Name, test.TestId)
+			if test.Runnable && test.Dependencies == atomic.DependencyStatusUnchecked {
+				fmt.Println("runnable (dependencies not checked)")
+			} else if test.Runnable {
+				fmt.Println("runnable")
+			} else {
+				fmt.Printf("not runnable (%s)\n", strings.Join(test.Reasons, "; "))
+			}
+		}
+	} else if outputFormat == OutputFormatJson {
+		PrintJson(advertisement)
+	} else if outputFormat == OutputFormatYaml {
+		PrintYaml(advertisement)
+	} else {
+		log.Fatalf("Unknown output format: %s", outputFormat)
+	}
+}
+
 func printTest(test atomic.Test, outputFormat string) {
 	if outputFormat == OutputFormatPlain {
 		printTestPlain(test)
@@ -211,7 +515,7 @@ func printTestPlain(test atomic.Test) {
 	fmt.Println()
 	fmt.Printf("Supported platforms: %s\n", strings.Join(test.SupportedPlatforms, ","))
 	fmt.Printf("Command type: %s\n", test.Executor.Name)
-	fmt.Printf("Requires elevation: %v\n", test.Executor.ElevationRequired)
+	fmt.Printf("Requires elevation: %v\n", test.RequiresElevation())
 	fmt.Printf("Total dependencies: %d\n", len(test.Dependencies))
 	fmt.Println()
 	fmt.Printf("Commands:\n\n%s\n", strings.TrimRight(test.Executor.Command, "\n"))
@@ -254,10 +558,57 @@ func printTestResult(result atomic.TestResult, outputFormat string) {
 	}
 }
 
+func printDryRunResult(result atomic.DryRunResult, outputFormat string) {
+	if outputFormat == OutputFormatPlain {
+		printDryRunResultPlain(result)
+		fmt.Println(lineSeparator)
+	} else if outputFormat == OutputFormatJson {
+		PrintJson(result)
+	} else if outputFormat == OutputFormatYaml {
+		PrintYaml(result)
+	} else {
+		log.Fatalf("Unknown output format: %s", outputFormat)
+	}
+}
+
+func printDryRunResultPlain(result atomic.DryRunResult) {
+	fmt.Printf("Test ID: %s\n", result.Test.AutoGeneratedGuid)
+	fmt.Printf("Test name: %s\n", result.Test.Name)
+	fmt.Printf("Runnable: %v\n", result.Runnable)
+	if result.Reason != "" {
+		fmt.Printf("Reason: %s\n", result.Reason)
+	}
+	fmt.Printf("Executor: %s (%s)\n", result.Executor.Name, result.Executor.Path)
+	for _, dependency := range result.Dependencies {
+		fmt.Println()
+		fmt.Printf("Dependency: %s\n", strings.TrimSpace(dependency.Description))
+		fmt.Printf("Executor: %s (%s)\n", dependency.Executor.Name, dependency.Executor.Path)
+		fmt.Printf("Prerequisite command:\n\n%s\n", strings.TrimRight(dependency.PrereqCommand, "\n"))
+		fmt.Println()
+		fmt.Printf("Get prerequisite command:\n\n%s\n", strings.TrimRight(dependency.GetPrereqCommand, "\n"))
+	}
+	fmt.Println()
+	fmt.Printf("Commands:\n\n%s\n", strings.TrimRight(result.Command, "\n"))
+	if result.CleanupCommand != "" {
+		fmt.Println()
+		fmt.Printf("Cleanup commands:\n\n%s\n", strings.TrimRight(result.CleanupCommand, "\n"))
+	}
+}
+
 func printTestResultPlain(result atomic.TestResult) {
 	fmt.Printf("Test ID: %s\n", result.Test.AutoGeneratedGuid)
 	fmt.Printf("Test result ID: %s\n", result.Id)
 	fmt.Printf("Time: %s\n", result.Time.Format(time.RFC3339))
+	fmt.Printf("Status: %s\n", result.Status)
+	if result.Reason != "" {
+		fmt.Printf("Reason: %s\n", result.Reason)
+	}
+	if result.Host != nil {
+		fmt.Printf("Host: %s (ID: %s)\n", result.Host.Hostname, result.HostId)
+	}
+	if result.User != nil {
+		fmt.Printf("User: %s (ID: %s)\n", result.User.Username, result.UserId)
+	}
 	fmt.Println()
 	fmt.Printf("Executed commands:\n\n")
 	for _, command := range result.ExecutedCommands {
@@ -272,18 +623,7 @@ func printTestResultPlain(result atomic.TestResult) {
 	}
 	fmt.Println()
 	fmt.Printf("Executables:\n\n")
-	var paths []string
-	for _, command := range result.ExecutedCommands {
-		for _, process := range command.GetProcesses() {
-			if process.Executable != nil {
-				path := process.Executable.Path
-				if path != "" && !slices.Contains(paths, path) {
-					paths = append(paths, path)
-				}
-			}
-		}
-	}
-	for _, path := range paths {
+	for _, path := range result.GetExecutables() {
 		fmt.Printf("- %s\n", path)
 	}
 }
@@ -292,7 +632,7 @@ func init() {
 
 	// Add commands.
 	rootCmd.AddCommand(testsCmd)
-	testsCmd.AddCommand(listTestsCmd, countTestsCmd, executeTestsCmd)
+	testsCmd.AddCommand(listTestsCmd, countTestsCmd, executeTestsCmd, cleanupTestsCmd, advertiseTestsCmd)
 
 	testsCmd.AddCommand(dependenciesCmd)
 	dependenciesCmd.AddCommand(listDependenciesCmd, countDependenciesCmd)
@@ -302,7 +642,56 @@ func init() {
 	flagset.StringP("atomics-dir", "", atomic.DefaultAtomicsDir, "Path to atomic-red-team/atomics directory")
 	flagset.StringP("password", "", "", "Password for decrypting atomics-dir")
 	flagset.StringP("output-format", "o", OutputFormatPlain, "Output format")
+	flagset.AddFlagSet(newTestSelectionFlagset())
+
+	// Pass the same flags to all commands.
+	listTestsCmd.Flags().AddFlagSet(&flagset)
+	countTestsCmd.Flags().AddFlagSet(&flagset)
+	executeTestsCmd.Flags().AddFlagSet(&flagset)
+	cleanupTestsCmd.Flags().AddFlagSet(&flagset)
+	advertiseTestsCmd.Flags().AddFlagSet(&flagset)
+	listDependenciesCmd.Flags().AddFlagSet(&flagset)
+	countDependenciesCmd.Flags().AddFlagSet(&flagset)
+
+	// Add flags for tabular output.
+	listTestsCmd.Flags().StringSliceP("columns", "", []string{}, "Columns to include when the output format is table or csv (default: "+strings.Join(defaultTestColumns, ",")+")")
+	listDependenciesCmd.Flags().StringSliceP("columns", "", []string{}, "Columns to include when the output format is table or csv (default: "+strings.Join(defaultDependencyColumns, ",")+")")
+
+	// Add flags for rendering output using Go templates.
+	templateFlagset := newTemplateFlagset()
+	listTestsCmd.Flags().AddFlagSet(templateFlagset)
+	executeTestsCmd.Flags().AddFlagSet(templateFlagset)
+	cleanupTestsCmd.Flags().AddFlagSet(templateFlagset)
+	listDependenciesCmd.Flags().AddFlagSet(templateFlagset)
 
+	// Add flags for exporting ATT&CK Navigator layers.
+	layerFlagset := pflag.FlagSet{}
+	layerFlagset.StringP("layer-output", "", "", "Write an ATT&CK Navigator layer to this path")
+	layerFlagset.StringP("layer-name", "", "Atomic Red Team", "Name of the ATT&CK Navigator layer")
+
+	listTestsCmd.Flags().AddFlagSet(&layerFlagset)
+	executeTestsCmd.Flags().AddFlagSet(&layerFlagset)
+
+	// Add flags for running tests.
+	executeTestsCmd.Flags().DurationP("timeout", "", 0, "Maximum amount of time that each test command may run for (e.g. 30s, 5m)")
+	executeTestsCmd.Flags().DurationP("dependency-timeout", "", 0, "Maximum amount of time that dependency resolution may take for each test")
+	executeTestsCmd.Flags().DurationP("cleanup-timeout", "", 0, "Maximum amount of time that each cleanup command may run for")
+	cleanupTestsCmd.Flags().DurationP("cleanup-timeout", "", 0, "Maximum amount of time that each cleanup command may run for")
+	executeTestsCmd.Flags().IntP("workers", "w", 1, "Number of tests to run concurrently")
+	executeTestsCmd.Flags().StringP("failure-policy", "", "continue", "Whether to continue after tests fail (continue, stop, or stop-after-N)")
+	executeTestsCmd.Flags().BoolP("dry-run", "", false, "Show the commands that would be executed without executing them")
+	executeTestsCmd.Flags().BoolP("save", "", false, "Save test results, including the output of commands, to the result store (see the results command)")
+	executeTestsCmd.Flags().StringP("events", "", "", "Path to a file to write events to (JSON lines)")
+	executeTestsCmd.Flags().BoolP("write-to-dirs", "", false, "Write test invocations, statuses, and results to the GO_ATOMIC_TEST_INVOCATION_*_DIR directories")
+	// Add flags for advertising tests.
+	advertiseTestsCmd.Flags().BoolP("check-dependencies", "", false, "Check whether the dependencies of each test are met by running their prerequisite commands")
+	advertiseTestsCmd.Flags().DurationP("dependency-timeout", "", 0, "Maximum amount of time that each dependency check may take")
+	advertiseTestsCmd.Flags().BoolP("runnable-only", "", false, "Only include tests that can be run on this host")
+}
+
+// newTestSelectionFlagset returns the flags that are used to select tests (see planTests).
+func newTestSelectionFlagset() *pflag.FlagSet {
+	flagset := &pflag.FlagSet{}
 	flagset.StringSliceP("id", "", []string{}, "Test IDs")
 	flagset.StringSliceP("name", "", []string{}, "Test names")
 	flagset.StringSliceP("description", "", []string{}, "Test descriptions")
@@ -314,10 +703,9 @@ func init() {
 	flagset.BoolP("elevation-required", "", false, "Elevation required")
 	flagset.BoolP("match-platform", "", false, "Match platform")
 
-	// Pass the same flags to all commands.
-	listTestsCmd.Flags().AddFlagSet(&flagset)
-	countTestsCmd.Flags().AddFlagSet(&flagset)
-	executeTestsCmd.Flags().AddFlagSet(&flagset)
-	listDependenciesCmd.Flags().AddFlagSet(&flagset)
-	countDependenciesCmd.Flags().AddFlagSet(&flagset)
+	flagset.Float64P("layer-min-score", "", 0, "Minimum score of techniques to select from ATT&CK Navigator layers")
+	flagset.Float64P("layer-max-score", "", 0, "Maximum score of techniques to select from ATT&CK Navigator layers")
+	flagset.StringSliceP("layer-color", "", []string{}, "Colors of techniques to select from ATT&CK Navigator layers")
+	flagset.StringSliceP("layer-tactic", "", []string{}, "Tactics of techniques to select from ATT&CK Navigator layers")
+	return flagset
 }
@@ -66,6 +66,7 @@ require (
 	github.com/spf13/pflag v1.0.5
 	github.com/whitfieldsdad/go-building-blocks v1.0.0
 	golang.org/x/sys v0.15.0
+	golang.org/x/term v0.15.0
 )
 
 replace github.com/whitfieldsdad/go-building-blocks => ../go-building-blocks
@@ -1,7 +1,11 @@
 package main
 
-import "github.com/whitfieldsdad/go-atomic-red-team/cmd"
+import (
+	"os"
+
+	"github.com/whitfieldsdad/go-atomic-red-team/cmd"
+)
 
 func main() {
-	cmd.Execute()
+	os.Exit(cmd.Execute())
 }
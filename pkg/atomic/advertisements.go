@@ -0,0 +1,155 @@
+package atomic
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/whitfieldsdad/go-building-blocks/pkg/bb"
+)
+
+const (
+	EventTypeTestAdvertisement = "test_advertisement"
+)
+
+const (
+	DependencyStatusMet       = "met"
+	DependencyStatusNotMet    = "not_met"
+	DependencyStatusUnchecked = "unchecked"
+)
+
+// TestAdvertisement lists the tests that have been checked against a host, and whether each of them can be run there.
+//
+// If dependencies weren't checked, tests are advertised as runnable regardless of whether their dependencies are met.
+type TestAdvertisement struct {
+	EventHeader         `yaml:",inline"`
+	Platform            string           `json:"platform" yaml:"platform"`
+	Elevated            bool             `json:"elevated" yaml:"elevated"`
+	DependenciesChecked bool             `json:"dependencies_checked" yaml:"dependencies_checked"`
+	Tests               []AdvertisedTest `json:"tests" yaml:"tests"`
+}
+
+func (TestAdvertisement) GetEventType() string {
+	return EventTypeTestAdvertisement
+}
+
+// GetRunnableTestIds returns the IDs of the tests that can be run on the host.
+func (a TestAdvertisement) GetRunnableTestIds() []string {
+	var ids []string
+	for _, test := range a.Tests {
+		if test.Runnable {
+			ids = append(ids, test.TestId)
+		}
+	}
+	return ids
+}
+
+// AdvertisedTest says whether a test can be run on a host, and if not, why not.
+type AdvertisedTest struct {
+	TestId            string   `json:"test_id" yaml:"test_id"`
+	Name              string   `json:"name" yaml:"name"`
+	AttackTechniqueId string   `json:"attack_technique_id,omitempty" yaml:"attack_technique_id,omitempty"`
+	Runnable          bool     `json:"runnable" yaml:"runnable"`
+	Reasons           []string `json:"reasons,omitempty" yaml:"reasons,omitempty"`
+	ExecutorPath      string   `json:"executor_path,omitempty" yaml:"executor_path,omitempty"`
+
+	// Dependencies is "met", "not_met", or "unchecked". Dependencies are unchecked if dependency checks were disabled, or
+	// if the test can't be run for other reasons.
+	Dependencies string `json:"dependencies" yaml:"dependencies"`
+}
+
+// AdvertisementOptions control how thoroughly tests are checked.
+type AdvertisementOptions struct {
+	// CheckDependencies runs the prerequisite command of each dependency. Dependencies are not resolved.
+	CheckDependencies bool
+
+	// DependencyTimeout is the maximum amount of time that each prerequisite command may run for.
+	DependencyTimeout time.Duration
+}
+
+// AdvertiseTests checks each test against this host: whether the platform is supported, whether elevation is
+// required, whether the test's executor is installed, and optionally whether its dependencies are met.
+func AdvertiseTests(ctx context.Context, atomicsDir string, tests []Test, identity Identity, opts *AdvertisementOptions) (*TestAdvertisement, error) {
+	if opts == nil {
+		opts = &AdvertisementOptions{}
+	}
+	elevated, err := bb.IsElevated()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check if current process is elevated")
+	}
+	advertisement := &TestAdvertisement{
+		EventHeader:         NewEventHeader(identity),
+		Platform:            runtime.GOOS,
+		Elevated:            elevated,
+		DependenciesChecked: opts.CheckDependencies,
+	}
+	for _, test := range tests {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		advertisement.Tests = append(advertisement.Tests, advertiseTest(ctx, atomicsDir, test, elevated, opts))
+	}
+	return advertisement, nil
+}
+
+func advertiseTest(ctx context.Context, atomicsDir string, test Test, elevated bool, opts *AdvertisementOptions) AdvertisedTest {
+	advertisedTest := AdvertisedTest{
+		TestId:            test.AutoGeneratedGuid,
+		Name:              test.Name,
+		AttackTechniqueId: test.AttackTechniqueId,
+		Dependencies:      DependencyStatusUnchecked,
+	}
+	var reasons []string
+	if test.IsManual() {
+		reasons = append(reasons, "manual tests are not supported")
+	}
+	if !test.MatchesCurrentPlatform() {
+		reasons = append(reasons, "unsupported platform")
+	}
+	if test.RequiresElevation() && !elevated {
+		reasons = append(reasons, "test requires elevation")
+	}
+	if !test.IsManual() {
+		path, err := GetExecutorPath(test.Executor.Name)
+		if err != nil {
+			reasons = append(reasons, err.Error())
+		}
+		advertisedTest.ExecutorPath = path
+	}
+
+	// There's no point in running prerequisite commands if the test can't be run anyway.
+	if len(reasons) == 0 && opts.CheckDependencies {
+		dependencyReasons := checkDependencies(ctx, atomicsDir, test, opts.DependencyTimeout)
+		if len(dependencyReasons) > 0 {
+			advertisedTest.Dependencies = DependencyStatusNotMet
+		} else {
+			advertisedTest.Dependencies = DependencyStatusMet
+		}
+		reasons = append(reasons, dependencyReasons...)
+	}
+	advertisedTest.Runnable = len(reasons) == 0
+	advertisedTest.Reasons = reasons
+	return advertisedTest
+}
+
+func checkDependencies(ctx context.Context, atomicsDir string, test Test, timeout time.Duration) []string {
+	var reasons []string
+	inputArguments := test.combineArgs(nil)
+	for _, dependency := range test.Dependencies {
+		if _, err := GetExecutorPath(dependency.ExecutorName); err != nil {
+			reasons = append(reasons, "dependency executor not found: "+dependency.ExecutorName)
+			continue
+		}
+		dependencyCtx, cancel := withTimeout(ctx, timeout)
+		_, met, err := dependency.checkDependency(dependencyCtx, atomicsDir, inputArguments)
+		cancel()
+		if err != nil {
+			reasons = append(reasons, "failed to check dependency: "+err.Error())
+		} else if !met {
+			reasons = append(reasons, "dependency not met: "+strings.TrimSpace(dependency.Description))
+		}
+	}
+	return reasons
+}
@@ -0,0 +1,254 @@
+package atomic
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultAgentPollInterval = 5 * time.Second
+)
+
+// Agent runs the tests requested by test invocation documents that are dropped into the request directory (see
+// docs/events/test_invocation.json).
+//
+// Each request is answered with a response in the response directory that says whether it was accepted. Accepted
+// requests are run one at a time, and their invocations, statuses, and results are written to the invocation, status,
+// and result directories.
+type Agent struct {
+	Tests       []Test
+	Directories Directories
+	Runner      *Runner
+
+	// PollInterval is how often the request directory is checked for new requests. If it isn't positive,
+	// DefaultAgentPollInterval is used.
+	PollInterval time.Duration
+}
+
+func NewAgent(atomicsDir string, tests []Test, dirs Directories) *Agent {
+	runner := NewRunner(atomicsDir, 1)
+	runner.Events = NewDirectoryEventSink(dirs)
+	return &Agent{
+		Tests:        tests,
+		Directories:  dirs,
+		PollInterval: DefaultAgentPollInterval,
+		Runner:       runner,
+	}
+}
+
+// Run processes requests until the context is cancelled.
+func (a *Agent) Run(ctx context.Context) error {
+	err := os.MkdirAll(a.Directories.TestInvocationRequestDir, 0755)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request directory")
+	}
+	a.recoverRequests()
+	log.Infof("Watching for test invocation requests in %s", a.Directories.TestInvocationRequestDir)
+	for {
+		err := a.ProcessRequests(ctx)
+		if err != nil {
+			log.Errorf("Failed to process test invocation requests: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(a.getPollInterval()):
+		}
+	}
+}
+
+func (a *Agent) getPollInterval() time.Duration {
+	if a.PollInterval <= 0 {
+		return DefaultAgentPollInterval
+	}
+	return a.PollInterval
+}
+
+// ProcessRequests processes every request that is currently in the request directory, oldest first.
+func (a *Agent) ProcessRequests(ctx context.Context) error {
+	paths, err := a.listRequests()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			return nil
+		}
+		a.processRequest(ctx, path)
+	}
+	return nil
+}
+
+// listRequests returns the paths to pending requests. Hidden files are ignored so that requests can be written
+// atomically (i.e. to a hidden temporary file, which is then renamed).
+func (a *Agent) listRequests() ([]string, error) {
+	entries, err := os.ReadDir(a.Directories.TestInvocationRequestDir)
+	if err != nil {
+		return nil, err
+	}
+	type request struct {
+		path    string
+		modTime time.Time
+	}
+	var requests []request
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		requests = append(requests, request{
+			path:    filepath.Join(a.Directories.TestInvocationRequestDir, name),
+			modTime: info.ModTime(),
+		})
+	}
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].modTime.Before(requests[j].modTime)
+	})
+	var paths []string
+	for _, r := range requests {
+		paths = append(paths, r.path)
+	}
+	return paths, nil
+}
+
+const claimedRequestSuffix = ".claimed"
+
+// recoverRequests completes the requests that were claimed, but never completed, by a previous instance of the agent
+// (e.g. because it crashed while running a test). Their tests may have been partially run, so they're recorded as
+// errored rather than being run again.
+func (a *Agent) recoverRequests() {
+	entries, err := os.ReadDir(a.Directories.TestInvocationRequestDir)
+	if err != nil {
+		log.Errorf("Failed to list claimed test invocation requests: %s", err)
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json"+claimedRequestSuffix) {
+			continue
+		}
+		claimedPath := filepath.Join(a.Directories.TestInvocationRequestDir, name)
+		invocation, test, err := a.readRequest(claimedPath)
+		if err != nil {
+			log.Errorf("Rejected test invocation request %s: %s", claimedPath, err)
+			a.respond(*invocation, false, err.Error())
+		} else {
+			err = errors.New("the agent stopped before the test completed")
+			a.Runner.AbortInvocation(*test, *invocation, err)
+			log.Warnf("Test invocation %s was interrupted by the agent stopping - recorded it as errored", invocation.Id)
+		}
+		os.Remove(claimedPath)
+	}
+}
+
+func (a *Agent) processRequest(ctx context.Context, path string) {
+	// Claim the request by hiding it, so that it's only processed once. Claimed requests that are left behind if the
+	// agent stops are handled by recoverRequests.
+	claimedPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+claimedRequestSuffix)
+	err := os.Rename(path, claimedPath)
+	if err != nil {
+		log.Errorf("Failed to claim test invocation request %s: %s", path, err)
+		return
+	}
+	defer os.Remove(claimedPath)
+
+	invocation, test, err := a.readRequest(claimedPath)
+	if err != nil {
+		log.Errorf("Rejected test invocation request %s: %s", path, err)
+		a.respond(*invocation, false, err.Error())
+		return
+	}
+	log.Infof("Accepted test invocation request %s (test: %s)", invocation.Id, test.GetDisplayName())
+	a.respond(*invocation, true, "")
+
+	result := a.Runner.RunInvocation(ctx, *test, *invocation)
+	log.Infof("Completed test invocation %s: %s", invocation.Id, result.Result.Status)
+}
+
+// testInvocationRequest is the part of a test invocation document that the requester is responsible for. The rest of
+// the document (i.e. the time, and the agent, host, and user IDs) is filled in by the agent.
+type testInvocationRequest struct {
+	Id             string                 `json:"id"`
+	TestId         string                 `json:"test_id"`
+	InputArguments map[string]interface{} `json:"input_arguments"`
+	Options        TestOptions            `json:"options"`
+	RunId          string                 `json:"run_id"`
+}
+
+// readRequest reads a claimed request. The input arguments of the request are validated against the requested test, so
+// that invalid requests are rejected rather than being accepted and then failing.
+func (a *Agent) readRequest(path string) (*TestInvocation, *Test, error) {
+	invocation := &TestInvocation{EventHeader: NewEventHeader(a.Runner.Identity)}
+	invocation.Id = getRequestId(path)
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return invocation, nil, err
+	}
+	var request testInvocationRequest
+	err = json.Unmarshal(blob, &request)
+	if err != nil {
+		return invocation, nil, errors.Wrap(err, "failed to parse test invocation")
+	}
+	if request.Id != "" {
+		invocation.Id = request.Id
+	}
+	invocation.TestId = request.TestId
+	invocation.InputArguments = request.InputArguments
+	invocation.Options = request.Options
+	invocation.RunId = request.RunId
+	err = validateId(invocation.Id)
+	if err != nil {
+		return invocation, nil, err
+	}
+	if invocation.TestId == "" {
+		return invocation, nil, errors.New("no test ID was provided")
+	}
+	for i := range a.Tests {
+		test := &a.Tests[i]
+		if test.AutoGeneratedGuid != invocation.TestId {
+			continue
+		}
+		inputArguments, err := test.ValidateInputArguments(invocation.InputArguments)
+		if err != nil {
+			return invocation, nil, err
+		}
+		invocation.InputArguments = inputArguments
+		return invocation, test, nil
+	}
+	return invocation, nil, errors.Errorf("unknown test: %s", invocation.TestId)
+}
+
+// getRequestId returns the ID of a request that doesn't have one, based on the name of its file (e.g. "abc" for
+// "abc.json" or ".abc.json.claimed").
+func getRequestId(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, claimedRequestSuffix)
+	name = strings.TrimPrefix(name, ".")
+	return strings.TrimSuffix(name, ".json")
+}
+
+func (a *Agent) respond(invocation TestInvocation, accepted bool, reason string) {
+	response := TestInvocationResponse{
+		EventHeader:      NewEventHeader(a.Runner.Identity),
+		TestId:           invocation.TestId,
+		TestInvocationId: invocation.Id,
+		Accepted:         accepted,
+		Reason:           reason,
+	}
+	err := writeJSONFile(a.Directories.TestInvocationResponseDir, response.Id, response)
+	if err != nil {
+		log.Errorf("Failed to write response to test invocation request %s: %s", invocation.Id, err)
+	}
+}
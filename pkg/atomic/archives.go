@@ -0,0 +1,257 @@
+package atomic
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvArchiveKey = "GO_ATOMIC_ARCHIVE_KEY"
+)
+
+// ArchiveOptions controls what is included in an archive created by CreateArchive.
+type ArchiveOptions struct {
+	// Tests limits the archive to the provided tests and the support files (i.e. the src and bin directories) of their
+	// ATT&CK techniques. If no tests are provided, the entire atomics directory is archived.
+	Tests []Test
+
+	// Password is used to encrypt the archive using age. If no password is provided, the archive isn't encrypted.
+	Password string
+}
+
+// CreateArchive writes the contents of an atomics directory to a gzipped tarball that can be read using ReadTests. Paths
+// within the archive are relative to the atomics directory (i.e. the same as "tar -czf atomics.tar.gz --directory
+// atomics .").
+func CreateArchive(w io.Writer, atomicsDir string, opts *ArchiveOptions) error {
+	if opts == nil {
+		opts = &ArchiveOptions{}
+	}
+	info, err := os.Stat(atomicsDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.Errorf("not a directory: %s", atomicsDir)
+	}
+
+	var encrypted io.WriteCloser
+	if opts.Password != "" {
+		recipient, err := age.NewScryptRecipient(opts.Password)
+		if err != nil {
+			return errors.Wrap(err, "failed to create age recipient")
+		}
+		encrypted, err = age.Encrypt(w, recipient)
+		if err != nil {
+			return errors.Wrap(err, "failed to encrypt archive")
+		}
+		w = encrypted
+	}
+	gz := gzip.NewWriter(w)
+	archive := &archiveWriter{tw: tar.NewWriter(gz), dirs: make(map[string]bool)}
+
+	if len(opts.Tests) == 0 {
+		err = archive.addDir(atomicsDir, atomicsDir)
+	} else {
+		err = archive.addTests(atomicsDir, opts.Tests)
+	}
+	if err != nil {
+		return err
+	}
+	err = archive.tw.Close()
+	if err != nil {
+		return err
+	}
+	err = gz.Close()
+	if err != nil {
+		return err
+	}
+	if encrypted != nil {
+		return encrypted.Close()
+	}
+	return nil
+}
+
+type archiveWriter struct {
+	tw   *tar.Writer
+	dirs map[string]bool
+}
+
+// addTests adds the test files that contain the provided tests, with any other tests removed, along with the src and
+// bin directories that are next to each test file.
+func (a *archiveWriter) addTests(atomicsDir string, tests []Test) error {
+	testIds := make(map[string]bool)
+	for _, test := range tests {
+		testIds[test.AutoGeneratedGuid] = true
+	}
+	var paths []string
+	err := filepath.WalkDir(atomicsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".yaml") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		blob, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		blob, ok, err := selectTestsFromYaml(blob, testIds)
+		if err != nil {
+			log.Warnf("Failed to read tests from file: %s: %s", path, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		err = a.addFile(atomicsDir, path, info, bytes.NewReader(blob), int64(len(blob)))
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{"src", "bin"} {
+			dir := filepath.Join(filepath.Dir(path), name)
+			if _, err := os.Stat(dir); err != nil {
+				continue
+			}
+			err = a.addDir(atomicsDir, dir)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// selectTestsFromYaml removes every test that isn't in the provided set from a test file. Other content (e.g. the
+// order of fields) is preserved. False is returned if none of the tests in the file were selected.
+func selectTestsFromYaml(blob []byte, testIds map[string]bool) ([]byte, bool, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal(blob, &doc)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, false, nil
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "atomic_tests" || root.Content[i+1].Kind != yaml.SequenceNode {
+			continue
+		}
+		tests := root.Content[i+1]
+		var selected []*yaml.Node
+		for _, test := range tests.Content {
+			var t struct {
+				AutoGeneratedGuid string `yaml:"auto_generated_guid"`
+			}
+			if test.Decode(&t) == nil && testIds[t.AutoGeneratedGuid] {
+				selected = append(selected, test)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, false, nil
+		}
+		if len(selected) == len(tests.Content) {
+			return blob, true, nil
+		}
+		tests.Content = selected
+
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		err = encoder.Encode(&doc)
+		if err != nil {
+			return nil, false, err
+		}
+		return buf.Bytes(), true, encoder.Close()
+	}
+	return nil, false, nil
+}
+
+// addDir recursively adds the regular files within a directory.
+func (a *archiveWriter) addDir(atomicsDir, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return a.addFile(atomicsDir, path, info, f, info.Size())
+	})
+}
+
+// addFile adds a file, and any parent directories that haven't been added yet, to the archive. Directory entries are
+// required so that the archive can be walked when it is read (see readTestsFromTarballFile).
+func (a *archiveWriter) addFile(atomicsDir, filePath string, info fs.FileInfo, r io.Reader, size int64) error {
+	rel, err := filepath.Rel(atomicsDir, filePath)
+	if err != nil {
+		return err
+	}
+	name := filepath.ToSlash(rel)
+	err = a.addParentDirs(name, info)
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     int64(info.Mode().Perm()),
+		ModTime:  info.ModTime(),
+	}
+	err = a.tw.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(a.tw, r)
+	return err
+}
+
+func (a *archiveWriter) addParentDirs(name string, info fs.FileInfo) error {
+	dir := path.Dir(name)
+	if dir == "." || a.dirs[dir] {
+		return nil
+	}
+	err := a.addParentDirs(dir, info)
+	if err != nil {
+		return err
+	}
+	a.dirs[dir] = true
+	return a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     dir + "/",
+		Mode:     0755,
+		ModTime:  info.ModTime(),
+	})
+}
@@ -0,0 +1,150 @@
+package atomic
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"filippo.io/age"
+)
+
+var archiveTestFiles = map[string]string{
+	"T1057/T1057.yaml": `attack_technique: T1057
+display_name: Process Discovery
+atomic_tests:
+- name: Process Discovery - ps
+  auto_generated_guid: 4ff64f0b-aaf2-4866-b39d-38d9791407cc
+  supported_platforms:
+  - linux
+  executor:
+    name: sh
+    command: ps
+- name: Process Discovery - script
+  auto_generated_guid: 5ff64f0b-aaf2-4866-b39d-38d9791407cc
+  supported_platforms:
+  - linux
+  executor:
+    name: sh
+    command: sh PathToAtomicsFolder/T1057/src/ps.sh
+`,
+	"T1057/src/ps.sh":  "ps aux\n",
+	"T1057/bin/ps.bin": "\x7fELF",
+	"T1003/T1003.yaml": `attack_technique: T1003
+display_name: OS Credential Dumping
+atomic_tests:
+- name: Credential Dumping
+  auto_generated_guid: 6ff64f0b-aaf2-4866-b39d-38d9791407cc
+  supported_platforms:
+  - windows
+  executor:
+    name: powershell
+    command: PathToAtomicsFolder\T1003\src\dump.ps1
+`,
+	"T1003/src/dump.ps1": "Write-Host dump\n",
+}
+
+func TestCreateArchive(t *testing.T) {
+	atomicsDir := t.TempDir()
+	for name, content := range archiveTestFiles {
+		path := filepath.Join(atomicsDir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests, err := ReadTests(atomicsDir, "", &TestFilter{Names: []string{"Process Discovery - script"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tests) != 1 {
+		t.Fatalf("expected 1 test, got %d", len(tests))
+	}
+
+	for _, password := range []string{"", "secret"} {
+		name := "unencrypted"
+		if password != "" {
+			name = "encrypted"
+		}
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := CreateArchive(buf, atomicsDir, &ArchiveOptions{Tests: tests, Password: password})
+			if err != nil {
+				t.Fatalf("failed to create archive: %s", err)
+			}
+			files := readArchive(t, buf, password)
+
+			var names []string
+			for name := range files {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			want := []string{"T1057/T1057.yaml", "T1057/bin/ps.bin", "T1057/src/ps.sh"}
+			if !slices.Equal(names, want) {
+				t.Fatalf("got files %v, want %v", names, want)
+			}
+			for _, name := range []string{"T1057/bin/ps.bin", "T1057/src/ps.sh"} {
+				if files[name] != archiveTestFiles[name] {
+					t.Errorf("%s: got %q, want %q", name, files[name], archiveTestFiles[name])
+				}
+			}
+
+			archivedTests, err := decodeTests([]byte(files["T1057/T1057.yaml"]))
+			if err != nil {
+				t.Fatalf("failed to decode archived tests: %s", err)
+			}
+			if len(archivedTests) != 1 || archivedTests[0].AutoGeneratedGuid != tests[0].AutoGeneratedGuid {
+				t.Errorf("expected only test %s to be archived, got %+v", tests[0].AutoGeneratedGuid, archivedTests)
+			}
+			if archivedTests[0].AttackTechniqueId != "T1057" || archivedTests[0].AttackTechniqueName != "Process Discovery" {
+				t.Errorf("expected the ATT&CK technique to be preserved, got %s: %s", archivedTests[0].AttackTechniqueId, archivedTests[0].AttackTechniqueName)
+			}
+		})
+	}
+}
+
+// readArchive returns the contents of each regular file in an archive created by CreateArchive.
+func readArchive(t *testing.T, r io.Reader, password string) map[string]string {
+	t.Helper()
+	if password != "" {
+		identity, err := age.NewScryptIdentity(password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err = age.Decrypt(r, identity)
+		if err != nil {
+			t.Fatalf("failed to decrypt archive: %s", err)
+		}
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatalf("failed to decompress archive: %s", err)
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("failed to read archive: %s", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		blob, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("failed to read %s: %s", header.Name, err)
+		}
+		files[header.Name] = string(blob)
+	}
+	return files
+}
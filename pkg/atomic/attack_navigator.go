@@ -0,0 +1,366 @@
+package atomic
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+const (
+	NavigatorLayerColorPassed  = "#8ec843"
+	NavigatorLayerColorFailed  = "#ff6666"
+	NavigatorLayerColorMixed   = "#ffe766"
+	NavigatorLayerColorCovered = "#66b1ff"
+	NavigatorLayerColorNotRun  = "#d9d9d9"
+)
+
+// NavigatorLayer is an ATT&CK Navigator layer.
+type NavigatorLayer struct {
+	Name        string                    `json:"name" yaml:"name"`
+	Versions    *NavigatorLayerVersions   `json:"versions,omitempty" yaml:"versions,omitempty"`
+	Domain      string                    `json:"domain,omitempty" yaml:"domain,omitempty"`
+	Description string                    `json:"description,omitempty" yaml:"description,omitempty"`
+	Techniques  []NavigatorLayerTechnique `json:"techniques" yaml:"techniques"`
+	Gradient    *NavigatorLayerGradient   `json:"gradient,omitempty" yaml:"gradient,omitempty"`
+	LegendItems []NavigatorLayerLegend    `json:"legendItems,omitempty" yaml:"legendItems,omitempty"`
+}
+
+type NavigatorLayerVersions struct {
+	Attack    string `json:"attack,omitempty" yaml:"attack,omitempty"`
+	Navigator string `json:"navigator,omitempty" yaml:"navigator,omitempty"`
+	Layer     string `json:"layer,omitempty" yaml:"layer,omitempty"`
+}
+
+type NavigatorLayerGradient struct {
+	Colors   []string `json:"colors" yaml:"colors"`
+	MinValue float64  `json:"minValue" yaml:"minValue"`
+	MaxValue float64  `json:"maxValue" yaml:"maxValue"`
+}
+
+type NavigatorLayerLegend struct {
+	Label string `json:"label" yaml:"label"`
+	Color string `json:"color" yaml:"color"`
+}
+
+// NewNavigatorLayer returns an empty ATT&CK Navigator layer for the Enterprise ATT&CK domain.
+func NewNavigatorLayer(name, description string) *NavigatorLayer {
+	return &NavigatorLayer{
+		Name: name,
+		Versions: &NavigatorLayerVersions{
+			Navigator: "4.9.1",
+			Layer:     "4.5",
+		},
+		Domain:      "enterprise-attack",
+		Description: description,
+		Techniques:  []NavigatorLayerTechnique{},
+	}
+}
+
+// NewNavigatorLayerFromTests returns an ATT&CK Navigator layer showing which techniques are covered by the provided
+// tests. The score of each technique is the number of tests that cover it.
+func NewNavigatorLayerFromTests(name string, tests []Test) *NavigatorLayer {
+	layer := NewNavigatorLayer(name, "Techniques covered by Atomic Red Team tests")
+	testsByTechnique := make(map[string][]Test)
+	for _, test := range tests {
+		testsByTechnique[test.AttackTechniqueId] = append(testsByTechnique[test.AttackTechniqueId], test)
+	}
+	maxScore := 0.0
+	for id, tests := range testsByTechnique {
+		var comments []string
+		for _, test := range tests {
+			comments = append(comments, fmt.Sprintf("%s (%s)", test.Name, test.AutoGeneratedGuid))
+		}
+		slices.Sort(comments)
+		score := float64(len(tests))
+		maxScore = max(maxScore, score)
+		layer.Techniques = append(layer.Techniques, NavigatorLayerTechnique{
+			TechniqueId: id,
+			Score:       &score,
+			Color:       NavigatorLayerColorCovered,
+			Comment:     strings.Join(comments, "\n"),
+		})
+	}
+	layer.Gradient = &NavigatorLayerGradient{
+		Colors:   []string{"#ffffff", NavigatorLayerColorCovered},
+		MinValue: 0,
+		MaxValue: max(maxScore, 1),
+	}
+	layer.LegendItems = []NavigatorLayerLegend{
+		{Label: "Covered", Color: NavigatorLayerColorCovered},
+	}
+	layer.finalize()
+	return layer
+}
+
+// NewNavigatorLayerFromTestResults returns an ATT&CK Navigator layer summarizing the outcome of the provided test
+// results. The score of each technique is the percentage of its tests that succeeded, not counting skipped tests.
+// Techniques whose tests were all skipped aren't scored.
+func NewNavigatorLayerFromTestResults(name string, results []TestResult) *NavigatorLayer {
+	layer := NewNavigatorLayer(name, "Outcomes of Atomic Red Team tests")
+	resultsByTechnique := make(map[string][]TestResult)
+	for _, result := range results {
+		id := result.Test.AttackTechniqueId
+		resultsByTechnique[id] = append(resultsByTechnique[id], result)
+	}
+	for id, results := range resultsByTechnique {
+		var comments []string
+		passed, ran := 0, 0
+		for _, result := range results {
+			if result.Succeeded() {
+				passed++
+			}
+			if result.Status != TestStatusSkipped {
+				ran++
+			}
+			comments = append(comments, fmt.Sprintf("%s (%s): %s", result.Test.Name, result.Test.AutoGeneratedGuid, result.Status))
+		}
+		slices.Sort(comments)
+		technique := NavigatorLayerTechnique{
+			TechniqueId: id,
+			Color:       NavigatorLayerColorNotRun,
+			Comment:     strings.Join(comments, "\n"),
+		}
+		if ran > 0 {
+			score := math.Round(100 * float64(passed) / float64(ran))
+			technique.Score = &score
+			technique.Color = NavigatorLayerColorMixed
+			if passed == ran {
+				technique.Color = NavigatorLayerColorPassed
+			} else if passed == 0 {
+				technique.Color = NavigatorLayerColorFailed
+			}
+		}
+		layer.Techniques = append(layer.Techniques, technique)
+	}
+	layer.Gradient = &NavigatorLayerGradient{
+		Colors:   []string{NavigatorLayerColorFailed, NavigatorLayerColorMixed, NavigatorLayerColorPassed},
+		MinValue: 0,
+		MaxValue: 100,
+	}
+	layer.LegendItems = []NavigatorLayerLegend{
+		{Label: "All tests passed", Color: NavigatorLayerColorPassed},
+		{Label: "Some tests failed", Color: NavigatorLayerColorMixed},
+		{Label: "All tests failed", Color: NavigatorLayerColorFailed},
+		{Label: "No tests ran", Color: NavigatorLayerColorNotRun},
+	}
+	layer.finalize()
+	return layer
+}
+
+// finalize sorts the techniques in the layer and expands any parent techniques that have scored sub-techniques so
+// that they're visible in the ATT&CK Navigator.
+func (layer *NavigatorLayer) finalize() {
+	parents := mapset.NewSet[string]()
+	listed := mapset.NewSet[string]()
+	for _, technique := range layer.Techniques {
+		listed.Add(technique.TechniqueId)
+		if parent, _, ok := strings.Cut(technique.TechniqueId, "."); ok {
+			parents.Add(parent)
+		}
+	}
+	for _, parent := range parents.Difference(listed).ToSlice() {
+		layer.Techniques = append(layer.Techniques, NavigatorLayerTechnique{TechniqueId: parent})
+	}
+	for i, technique := range layer.Techniques {
+		if parents.Contains(technique.TechniqueId) {
+			layer.Techniques[i].ShowSubtechniques = true
+		}
+	}
+	slices.SortFunc(layer.Techniques, func(a, b NavigatorLayerTechnique) int {
+		return strings.Compare(a.TechniqueId, b.TechniqueId)
+	})
+}
+
+// WriteNavigatorLayer writes an ATT&CK Navigator layer to a JSON file.
+func WriteNavigatorLayer(path string, layer NavigatorLayer) error {
+	blob, err := json.MarshalIndent(layer, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "JSON serialization failed")
+	}
+	return os.WriteFile(path, blob, 0644)
+}
+
+// NavigatorLayerTechnique is a technique (or sub-technique) within an ATT&CK Navigator layer.
+type NavigatorLayerTechnique struct {
+	TechniqueId       string   `json:"techniqueID" yaml:"techniqueID"`
+	Tactic            string   `json:"tactic,omitempty" yaml:"tactic,omitempty"`
+	Score             *float64 `json:"score,omitempty" yaml:"score,omitempty"`
+	Color             string   `json:"color,omitempty" yaml:"color,omitempty"`
+	Comment           string   `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Enabled           *bool    `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	ShowSubtechniques bool     `json:"showSubtechniques,omitempty" yaml:"showSubtechniques,omitempty"`
+}
+
+func (t NavigatorLayerTechnique) IsEnabled() bool {
+	return t.Enabled == nil || *t.Enabled
+}
+
+// NavigatorLayerPlan is a test plan that selects tests using the techniques in an ATT&CK Navigator layer.
+//
+// Parent techniques are expanded to include all of their sub-techniques, unless a sub-technique is explicitly excluded
+// by the layer (e.g. because it is disabled, or doesn't meet the score or color thresholds).
+type NavigatorLayerPlan struct {
+	AtomicsDir string         `json:"atomics_dir,omitempty" yaml:"atomics_dir,omitempty"`
+	Layer      NavigatorLayer `json:"layer" yaml:"layer"`
+
+	// MinimumScore excludes techniques without a score, or with a score lower than this value.
+	MinimumScore *float64 `json:"minimum_score,omitempty" yaml:"minimum_score,omitempty"`
+
+	// MaximumScore excludes techniques without a score, or with a score higher than this value.
+	MaximumScore *float64 `json:"maximum_score,omitempty" yaml:"maximum_score,omitempty"`
+
+	// Colors excludes techniques which haven't been assigned one of these colors.
+	Colors []string `json:"colors,omitempty" yaml:"colors,omitempty"`
+
+	// Tactics excludes techniques which haven't been assigned to one of these tactics (e.g. "credential-access").
+	Tactics []string `json:"tactics,omitempty" yaml:"tactics,omitempty"`
+
+	TestOptions
+}
+
+func NewNavigatorLayerPlan(layer NavigatorLayer) *NavigatorLayerPlan {
+	return &NavigatorLayerPlan{
+		Layer: layer,
+	}
+}
+
+// GetAtomicsDir returns the path to the atomics directory that the tests should be read from, or an empty string if the
+// plan doesn't specify one.
+func (plan NavigatorLayerPlan) GetAtomicsDir() string {
+	return expandHomeDir(plan.AtomicsDir)
+}
+
+func (plan NavigatorLayerPlan) GetTestFilters() []TestFilter {
+	var filters []TestFilter
+	for _, entry := range plan.GetEntries() {
+		if !entry.Excluded {
+			filters = append(filters, entry.Filter)
+		}
+	}
+	return filters
+}
+
+func (plan NavigatorLayerPlan) GetTestOptions() TestOptions {
+	return plan.TestOptions
+}
+
+func (plan NavigatorLayerPlan) GetEntries() []TestPlanEntry {
+	included, excluded := plan.getAttackTechniqueIds()
+	opts := plan.GetTestOptions()
+
+	// Explicit exclusions must come first so that they take precedence over expanded parent techniques.
+	var entries []TestPlanEntry
+	for _, id := range excluded {
+		entries = append(entries, TestPlanEntry{
+			Filter:   TestFilter{AttackTechniqueIds: []string{id}},
+			Excluded: true,
+		})
+	}
+	for _, id := range included {
+		patterns := []string{id}
+		if !strings.Contains(id, ".") {
+			patterns = append(patterns, id+".*")
+		}
+		entries = append(entries, TestPlanEntry{
+			Filter:  TestFilter{AttackTechniqueIds: patterns},
+			Options: opts,
+		})
+	}
+	return entries
+}
+
+// getAttackTechniqueIds returns the IDs of techniques that are included or excluded by the layer. A technique is
+// included if any of its entries in the layer are selected (e.g. for one of several tactics).
+func (plan NavigatorLayerPlan) getAttackTechniqueIds() ([]string, []string) {
+	included := mapset.NewSet[string]()
+	listed := mapset.NewSet[string]()
+	for _, technique := range plan.Layer.Techniques {
+		id := strings.ToUpper(strings.TrimSpace(technique.TechniqueId))
+		if id == "" {
+			continue
+		}
+		listed.Add(id)
+		if plan.includesTechnique(technique) {
+			included.Add(id)
+		}
+	}
+	excluded := listed.Difference(included).ToSlice()
+	slices.Sort(excluded)
+	ids := included.ToSlice()
+	slices.Sort(ids)
+	return ids, excluded
+}
+
+func (plan NavigatorLayerPlan) includesTechnique(technique NavigatorLayerTechnique) bool {
+	if !technique.IsEnabled() {
+		return false
+	}
+	if plan.MinimumScore != nil && (technique.Score == nil || *technique.Score < *plan.MinimumScore) {
+		return false
+	}
+	if plan.MaximumScore != nil && (technique.Score == nil || *technique.Score > *plan.MaximumScore) {
+		return false
+	}
+	if len(plan.Colors) > 0 && !slices.ContainsFunc(plan.Colors, func(color string) bool {
+		return strings.EqualFold(color, technique.Color)
+	}) {
+		return false
+	}
+	if len(plan.Tactics) > 0 && !slices.ContainsFunc(plan.Tactics, func(tactic string) bool {
+		return strings.EqualFold(tactic, technique.Tactic)
+	}) {
+		return false
+	}
+	return true
+}
+
+// isNavigatorLayerPlan returns true if the data is a test plan which wraps an ATT&CK Navigator layer, allowing the
+// score, color, and tactic thresholds to be provided alongside the layer (e.g. {"layer": {...}, "minimum_score": 50}).
+func isNavigatorLayerPlan(data map[string]interface{}) bool {
+	layer, ok := data["layer"].(map[string]interface{})
+	return ok && isAttackNavigatorLayer(layer)
+}
+
+func parseNavigatorLayerPlan(data map[string]interface{}) (*NavigatorLayerPlan, error) {
+	layer, err := parseNavigatorLayer(data["layer"].(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	plan := NewNavigatorLayerPlan(*layer)
+
+	// The layer is decoded separately since layers contain many fields that aren't used to select tests.
+	fields := make(map[string]interface{})
+	for k, v := range data {
+		if k != "layer" {
+			fields[k] = v
+		}
+	}
+	err = decodeTestPlan(fields, plan)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func parseNavigatorLayer(data map[string]interface{}) (*NavigatorLayer, error) {
+	layer := &NavigatorLayer{}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName: "json",
+		Result:  layer,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = decoder.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return layer, nil
+}
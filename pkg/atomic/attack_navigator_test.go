@@ -0,0 +1,82 @@
+package atomic
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewNavigatorLayerFromTestResults(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []TestStatus
+		wantScore *float64
+		wantColor string
+	}{
+		{name: "all passed", statuses: []TestStatus{TestStatusPassed, TestStatusPassed}, wantScore: ptr(100.0), wantColor: NavigatorLayerColorPassed},
+		{name: "all failed", statuses: []TestStatus{TestStatusFailed, TestStatusErrored, TestStatusTimedOut}, wantScore: ptr(0.0), wantColor: NavigatorLayerColorFailed},
+		{name: "some failed", statuses: []TestStatus{TestStatusPassed, TestStatusFailed, TestStatusFailed}, wantScore: ptr(33.0), wantColor: NavigatorLayerColorMixed},
+		{name: "skipped tests aren't counted", statuses: []TestStatus{TestStatusPassed, TestStatusSkipped}, wantScore: ptr(100.0), wantColor: NavigatorLayerColorPassed},
+		{name: "skipped and failed", statuses: []TestStatus{TestStatusSkipped, TestStatusFailed}, wantScore: ptr(0.0), wantColor: NavigatorLayerColorFailed},
+		{name: "all skipped", statuses: []TestStatus{TestStatusSkipped, TestStatusSkipped}, wantColor: NavigatorLayerColorNotRun},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []TestResult
+			for i, status := range tt.statuses {
+				test := Test{Name: fmt.Sprintf("Test %d", i), AutoGeneratedGuid: fmt.Sprintf("%d", i), AttackTechniqueId: "T1003.001"}
+				results = append(results, TestResult{Test: test, Status: status})
+			}
+			layer := NewNavigatorLayerFromTestResults("results", results)
+
+			// The parent technique is added so that the sub-technique is visible.
+			if len(layer.Techniques) != 2 {
+				t.Fatalf("expected 2 techniques, got %+v", layer.Techniques)
+			}
+			parent, technique := layer.Techniques[0], layer.Techniques[1]
+			if parent.TechniqueId != "T1003" || !parent.ShowSubtechniques || parent.Score != nil {
+				t.Errorf("unexpected parent technique: %+v", parent)
+			}
+			if technique.TechniqueId != "T1003.001" {
+				t.Fatalf("unexpected technique: %s", technique.TechniqueId)
+			}
+			if (technique.Score == nil) != (tt.wantScore == nil) || (technique.Score != nil && *technique.Score != *tt.wantScore) {
+				t.Errorf("got score %v, want %v", formatScore(technique.Score), formatScore(tt.wantScore))
+			}
+			if technique.Color != tt.wantColor {
+				t.Errorf("got color %s, want %s", technique.Color, tt.wantColor)
+			}
+		})
+	}
+}
+
+func TestNewNavigatorLayerFromTests(t *testing.T) {
+	tests := []Test{
+		{Name: "a", AutoGeneratedGuid: "1", AttackTechniqueId: "T1057"},
+		{Name: "b", AutoGeneratedGuid: "2", AttackTechniqueId: "T1057"},
+		{Name: "c", AutoGeneratedGuid: "3", AttackTechniqueId: "T1003"},
+	}
+	layer := NewNavigatorLayerFromTests("coverage", tests)
+	want := map[string]float64{"T1003": 1, "T1057": 2}
+	if len(layer.Techniques) != len(want) {
+		t.Fatalf("expected %d techniques, got %+v", len(want), layer.Techniques)
+	}
+	for _, technique := range layer.Techniques {
+		if technique.Score == nil || *technique.Score != want[technique.TechniqueId] {
+			t.Errorf("%s: got score %s, want %v", technique.TechniqueId, formatScore(technique.Score), want[technique.TechniqueId])
+		}
+	}
+	if layer.Gradient.MaxValue != 2 {
+		t.Errorf("got gradient maximum %v, want 2", layer.Gradient.MaxValue)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func formatScore(score *float64) string {
+	if score == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%v", *score)
+}
@@ -0,0 +1,269 @@
+package atomic
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Scope is a permission that can be granted to a bearer token. Each scope includes the scopes before it: "run" allows
+// listing tests and running tests which don't require elevation, "run-elevated" also allows running tests which
+// require elevation, and "run-with-options" also allows test plans to set input arguments and test options. Input
+// arguments are substituted into commands, so "run-with-options" allows running arbitrary commands.
+type Scope string
+
+const (
+	ScopeList           Scope = "list"
+	ScopeRun            Scope = "run"
+	ScopeRunElevated    Scope = "run-elevated"
+	ScopeRunWithOptions Scope = "run-with-options"
+)
+
+var scopeLevels = []Scope{ScopeList, ScopeRun, ScopeRunElevated, ScopeRunWithOptions}
+
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid bearer token")
+)
+
+// Includes returns true if the scope grants the other scope.
+func (s Scope) Includes(other Scope) bool {
+	i := slices.Index(scopeLevels, s)
+	j := slices.Index(scopeLevels, other)
+	return i >= 0 && j >= 0 && i >= j
+}
+
+// Token is a bearer token. Tokens can be provided in plain text or as a hex-encoded SHA-256 hash, so that token files
+// don't have to contain secrets.
+type Token struct {
+	Name   string  `json:"name" yaml:"name"`
+	Token  string  `json:"token,omitempty" yaml:"token,omitempty"`
+	SHA256 string  `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+	Scopes []Scope `json:"scopes" yaml:"scopes"`
+}
+
+func (t Token) getHash() ([]byte, error) {
+	if t.SHA256 != "" {
+		hash, err := hex.DecodeString(t.SHA256)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, errors.Errorf("invalid SHA-256 hash for token: %s", t.Name)
+		}
+		return hash, nil
+	}
+	if t.Token == "" {
+		return nil, errors.Errorf("no token or SHA-256 hash was provided for token: %s", t.Name)
+	}
+	hash := sha256.Sum256([]byte(t.Token))
+	return hash[:], nil
+}
+
+// HasScope returns true if any of the token's scopes grant the provided scope.
+func (t Token) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s.Includes(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadTokens reads a list of tokens from a JSON or YAML file.
+func ReadTokens(path string) ([]Token, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []Token
+	err = yaml.Unmarshal(blob, &tokens)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse tokens")
+	}
+	for _, token := range tokens {
+		if _, err := token.getHash(); err != nil {
+			return nil, err
+		}
+		for _, scope := range token.Scopes {
+			if !slices.Contains(scopeLevels, scope) {
+				return nil, errors.Errorf("unknown scope for token %s: %s", token.Name, scope)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// AuditRecord records an authorization decision.
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Token      string    `json:"token,omitempty"`
+	ClientCert string    `json:"client_cert,omitempty"`
+	Scope      Scope     `json:"scope"`
+	Allowed    bool      `json:"allowed"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// Authorizer checks the bearer token of each request against the scope that the request requires, and records each
+// decision in the audit log. If no tokens have been configured, every request is allowed.
+type Authorizer struct {
+	Tokens   []Token
+	AuditLog io.Writer
+
+	mu sync.Mutex
+}
+
+func NewAuthorizer(tokens []Token, auditLog io.Writer) *Authorizer {
+	return &Authorizer{
+		Tokens:   tokens,
+		AuditLog: auditLog,
+	}
+}
+
+// Authorize returns nil if the request is allowed to do something that requires the provided scope.
+func (a *Authorizer) Authorize(r *http.Request, scope Scope) error {
+	record := AuditRecord{
+		Time:       time.Now(),
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		ClientCert: getClientCertName(r),
+		Scope:      scope,
+	}
+	err := a.authorize(r, scope, &record)
+	record.Allowed = err == nil
+	if err != nil {
+		record.Reason = err.Error()
+	}
+	a.audit(record)
+	return err
+}
+
+func (a *Authorizer) authorize(r *http.Request, scope Scope, record *AuditRecord) error {
+	if len(a.Tokens) == 0 {
+		return nil
+	}
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || bearer == "" {
+		return ErrMissingToken
+	}
+	token := a.findToken(bearer)
+	if token == nil {
+		return ErrInvalidToken
+	}
+	record.Token = token.Name
+	if !token.HasScope(scope) {
+		return errors.Errorf("token does not have the %s scope", scope)
+	}
+	return nil
+}
+
+func (a *Authorizer) findToken(bearer string) *Token {
+	hash := sha256.Sum256([]byte(bearer))
+	var match *Token
+	for i := range a.Tokens {
+		expected, err := a.Tokens[i].getHash()
+		if err != nil {
+			continue
+		}
+		// Check every token so that the time taken doesn't depend on which token matched.
+		if subtle.ConstantTimeCompare(hash[:], expected) == 1 && match == nil {
+			match = &a.Tokens[i]
+		}
+	}
+	return match
+}
+
+func (a *Authorizer) audit(record AuditRecord) {
+	if !record.Allowed {
+		log.Warnf("Denied %s %s from %s: %s", record.Method, record.Path, record.RemoteAddr, record.Reason)
+	}
+	if a.AuditLog == nil {
+		return
+	}
+	blob, err := json.Marshal(record)
+	if err != nil {
+		log.Errorf("Failed to encode audit record: %s", err)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.AuditLog.Write(append(blob, '\n'))
+	if err != nil {
+		log.Errorf("Failed to write audit record: %s", err)
+	}
+}
+
+func getClientCertName(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	cert := r.TLS.PeerCertificates[0]
+	return cert.Subject.CommonName + " (" + getCertFingerprint(cert) + ")"
+}
+
+func getCertFingerprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(hash[:])
+}
+
+// TLSOptions configure TLS for the API. If a client CA is provided, clients must present a certificate that was issued
+// by it, and if client certificate pins are provided, the client's certificate must also have one of the provided
+// SHA-256 fingerprints.
+type TLSOptions struct {
+	CertFile       string
+	KeyFile        string
+	ClientCAFile   string
+	ClientCertPins []string
+}
+
+func (o TLSOptions) GetTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if o.ClientCAFile != "" {
+		blob, err := os.ReadFile(o.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read client CA")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(blob) {
+			return nil, errors.Errorf("no certificates found in client CA file: %s", o.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	} else if len(o.ClientCertPins) > 0 {
+		config.ClientAuth = tls.RequireAnyClientCert
+	}
+	if len(o.ClientCertPins) > 0 {
+		pins := make([]string, 0, len(o.ClientCertPins))
+		for _, pin := range o.ClientCertPins {
+			pins = append(pins, strings.ToLower(strings.ReplaceAll(pin, ":", "")))
+		}
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("no client certificate was provided")
+			}
+			hash := sha256.Sum256(rawCerts[0])
+			if !slices.Contains(pins, hex.EncodeToString(hash[:])) {
+				return errors.New("client certificate is not pinned")
+			}
+			return nil
+		}
+	}
+	return config, nil
+}
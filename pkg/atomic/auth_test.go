@@ -0,0 +1,206 @@
+package atomic
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScopeIncludes(t *testing.T) {
+	tests := []struct {
+		scope Scope
+		other Scope
+		want  bool
+	}{
+		{ScopeList, ScopeList, true},
+		{ScopeList, ScopeRun, false},
+		{ScopeList, ScopeRunElevated, false},
+		{ScopeRun, ScopeList, true},
+		{ScopeRun, ScopeRun, true},
+		{ScopeRun, ScopeRunElevated, false},
+		{ScopeRunElevated, ScopeList, true},
+		{ScopeRunElevated, ScopeRun, true},
+		{ScopeRunElevated, ScopeRunElevated, true},
+		{ScopeRunElevated, ScopeRunWithOptions, false},
+		{ScopeRunWithOptions, ScopeRunElevated, true},
+		{Scope("admin"), ScopeList, false},
+		{ScopeRunElevated, Scope("admin"), false},
+	}
+	for _, tt := range tests {
+		if got := tt.scope.Includes(tt.other); got != tt.want {
+			t.Errorf("%s.Includes(%s): got %t, want %t", tt.scope, tt.other, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	hash := sha256.Sum256([]byte("hashed-secret"))
+	tokens := []Token{
+		{Name: "lister", Token: "list-secret", Scopes: []Scope{ScopeList}},
+		{Name: "runner", Token: "run-secret", Scopes: []Scope{ScopeRun}},
+		{Name: "admin", SHA256: hex.EncodeToString(hash[:]), Scopes: []Scope{ScopeRunElevated}},
+	}
+	tests := []struct {
+		name    string
+		bearer  string
+		scope   Scope
+		wantErr error
+		allowed bool
+	}{
+		{name: "missing token", scope: ScopeList, wantErr: ErrMissingToken},
+		{name: "invalid token", bearer: "wrong-secret", scope: ScopeList, wantErr: ErrInvalidToken},
+		{name: "list token can list", bearer: "list-secret", scope: ScopeList, allowed: true},
+		{name: "list token can't run", bearer: "list-secret", scope: ScopeRun},
+		{name: "run token can list", bearer: "run-secret", scope: ScopeList, allowed: true},
+		{name: "run token can run", bearer: "run-secret", scope: ScopeRun, allowed: true},
+		{name: "run token can't run elevated tests", bearer: "run-secret", scope: ScopeRunElevated},
+		{name: "hashed token can run elevated tests", bearer: "hashed-secret", scope: ScopeRunElevated, allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditLog := &bytes.Buffer{}
+			auth := NewAuthorizer(tokens, auditLog)
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/tests", nil)
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			err := auth.Authorize(r, tt.scope)
+			if tt.allowed && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			} else if !tt.allowed && err == nil {
+				t.Fatal("expected the request to be denied")
+			}
+			if tt.wantErr != nil && err != tt.wantErr {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+
+			var record AuditRecord
+			err = json.Unmarshal(auditLog.Bytes(), &record)
+			if err != nil {
+				t.Fatalf("failed to parse audit record: %s", err)
+			}
+			if record.Allowed != tt.allowed || record.Scope != tt.scope {
+				t.Errorf("unexpected audit record: %+v", record)
+			}
+			if tt.bearer != "" && strings.Contains(auditLog.String(), tt.bearer) {
+				t.Errorf("audit record contains the bearer token: %s", auditLog.String())
+			}
+		})
+	}
+}
+
+func TestAuthorizeWithoutTokens(t *testing.T) {
+	auth := NewAuthorizer(nil, nil)
+	for _, scope := range scopeLevels {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/plans", nil)
+		err := auth.Authorize(r, scope)
+		if err != nil {
+			t.Errorf("%s: expected the request to be allowed, got %s", scope, err)
+		}
+	}
+}
+
+func TestServerRequiresScopes(t *testing.T) {
+	atomicsDir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(atomicsDir, "T1057"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := []byte(`attack_technique: T1057
+display_name: Process Discovery
+atomic_tests:
+- name: Process Discovery - ps
+  auto_generated_guid: 4ff64f0b-aaf2-4866-b39d-38d9791407cc
+  supported_platforms:
+  - linux
+  - macos
+  - windows
+  input_arguments:
+    output_file:
+      description: path of output file
+      type: path
+      default: /tmp/loot.txt
+  executor:
+    name: sh
+    command: ps > #{output_file}
+- name: Process Discovery - elevated
+  auto_generated_guid: 11111111-aaf2-4866-b39d-38d9791407cc
+  supported_platforms:
+  - linux
+  - macos
+  - windows
+  executor:
+    name: sh
+    elevation_required: true
+    command: id
+- name: Process Discovery - elevated dependency
+  auto_generated_guid: 22222222-aaf2-4866-b39d-38d9791407cc
+  supported_platforms:
+  - linux
+  - macos
+  - windows
+  dependencies:
+  - description: id must exist
+    prereq_command: which id
+    get_prereq_command: apt-get install -y coreutils
+    elevation_required: true
+  executor:
+    name: sh
+    command: id
+`)
+	err = os.WriteFile(filepath.Join(atomicsDir, "T1057", "T1057.yaml"), blob, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(atomicsDir, "")
+	server.Auth = NewAuthorizer([]Token{
+		{Name: "runner", Token: "run-secret", Scopes: []Scope{ScopeRun}},
+		{Name: "elevated", Token: "elevated-secret", Scopes: []Scope{ScopeRunElevated}},
+	}, nil)
+	handler := server.Handler(context.Background())
+
+	// The token may list tests...
+	r := httptest.NewRequest(http.MethodGet, apiPrefix+"/tests", nil)
+	r.Header.Set("Authorization", "Bearer run-secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /tests: got status %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	// ...but may not run tests that require elevation, or set input arguments or test options.
+	tests := []struct {
+		name  string
+		token string
+		plan  string
+	}{
+		{name: "elevated test", token: "run-secret", plan: `{"ids": ["11111111-aaf2-4866-b39d-38d9791407cc"]}`},
+		{name: "elevated dependency", token: "run-secret", plan: `{"ids": ["22222222-aaf2-4866-b39d-38d9791407cc"]}`},
+		{name: "input arguments", token: "elevated-secret", plan: `{"ids": ["4ff64f0b-aaf2-4866-b39d-38d9791407cc"], "input_arguments": {"output_file": "/tmp/x; id"}}`},
+		{name: "test options", token: "elevated-secret", plan: `{"tests": [{"id": "4ff64f0b-aaf2-4866-b39d-38d9791407cc", "timeout": 1}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, apiPrefix+"/plans", strings.NewReader(tt.plan))
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("POST /plans: got status %d, want %d: %s", w.Code, http.StatusForbidden, w.Body.String())
+			}
+		})
+	}
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	if len(server.invocations) > 0 {
+		t.Errorf("expected no tests to be queued, got %d", len(server.invocations))
+	}
+}
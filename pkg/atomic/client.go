@@ -143,8 +143,12 @@ func decodeTests(data []byte) ([]Test, error) {
 	for i, test := range tests {
 		test.AttackTechniqueId = attackTechniqueId
 		test.AttackTechniqueName = attackTechniqueName
+		dependencyExecutorName := test.DependencyExecutorName
+		if dependencyExecutorName == "" {
+			dependencyExecutorName = test.Executor.Name
+		}
 		for j, dependency := range test.Dependencies {
-			dependency.ExecutorName = test.DependencyExecutorName
+			dependency.ExecutorName = dependencyExecutorName
 			dependency.InputArguments = test.InputArguments
 			test.Dependencies[j] = dependency
 		}
@@ -0,0 +1,178 @@
+package atomic
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	TestChangeAdded         = "added"
+	TestChangeRemoved       = "removed"
+	TestChangeNewlyFailing  = "newly_failing"
+	TestChangeNewlyPassing  = "newly_passing"
+	TestChangeNewlySkipped  = "newly_skipped"
+	TestChangeStatusChanged = "status_changed"
+	TestChangeChanged       = "changed"
+	TestChangeUnchanged     = "unchanged"
+)
+
+// TestResultDiff compares two sets of test results (e.g. from last week's run and today's run).
+type TestResultDiff struct {
+	Tests   []TestDiff     `json:"tests" yaml:"tests"`
+	Summary map[string]int `json:"summary" yaml:"summary"`
+}
+
+// GetChangedTests returns the tests that didn't behave the same way in both sets of results.
+func (d TestResultDiff) GetChangedTests() []TestDiff {
+	var tests []TestDiff
+	for _, test := range d.Tests {
+		if test.Change != TestChangeUnchanged {
+			tests = append(tests, test)
+		}
+	}
+	return tests
+}
+
+// HasRegressions returns true if any tests are newly failing.
+func (d TestResultDiff) HasRegressions() bool {
+	return d.Summary[TestChangeNewlyFailing] > 0
+}
+
+// TestDiff compares the results of a test. Fields ending in A refer to the first set of results, and fields ending in
+// B refer to the second.
+type TestDiff struct {
+	HostId             string        `json:"host_id,omitempty" yaml:"host_id,omitempty"`
+	Hostname           string        `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	TestId             string        `json:"test_id" yaml:"test_id"`
+	Name               string        `json:"name" yaml:"name"`
+	AttackTechniqueId  string        `json:"attack_technique_id,omitempty" yaml:"attack_technique_id,omitempty"`
+	Change             string        `json:"change" yaml:"change"`
+	StatusA            TestStatus    `json:"status_a,omitempty" yaml:"status_a,omitempty"`
+	StatusB            TestStatus    `json:"status_b,omitempty" yaml:"status_b,omitempty"`
+	ExitCodeA          *int          `json:"exit_code_a,omitempty" yaml:"exit_code_a,omitempty"`
+	ExitCodeB          *int          `json:"exit_code_b,omitempty" yaml:"exit_code_b,omitempty"`
+	AddedExecutables   []string      `json:"added_executables,omitempty" yaml:"added_executables,omitempty"`
+	RemovedExecutables []string      `json:"removed_executables,omitempty" yaml:"removed_executables,omitempty"`
+	DurationA          time.Duration `json:"duration_a" yaml:"duration_a"`
+	DurationB          time.Duration `json:"duration_b" yaml:"duration_b"`
+	DurationDelta      time.Duration `json:"duration_delta" yaml:"duration_delta"`
+}
+
+// ExitCodeChanged returns true if the test exited with a different exit code in each set of results.
+func (d TestDiff) ExitCodeChanged() bool {
+	return !equalExitCodes(d.ExitCodeA, d.ExitCodeB)
+}
+
+// DiffTestResults compares two sets of test results by host and test GUID, so that results from different hosts are
+// compared separately. If a test has several results from the same host in the same set, the most recent result is
+// used.
+func DiffTestResults(a, b []TestResult) TestResultDiff {
+	resultsA := latestResultsByTest(a)
+	resultsB := latestResultsByTest(b)
+
+	var ids []string
+	for id := range resultsA {
+		ids = append(ids, id)
+	}
+	for id := range resultsB {
+		if _, ok := resultsA[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+
+	diff := TestResultDiff{Summary: make(map[string]int)}
+	for _, id := range ids {
+		test := diffTestResult(resultsA[id], resultsB[id])
+		diff.Tests = append(diff.Tests, test)
+		diff.Summary[test.Change]++
+	}
+	sort.SliceStable(diff.Tests, func(i, j int) bool {
+		if diff.Tests[i].HostId != diff.Tests[j].HostId {
+			return diff.Tests[i].HostId < diff.Tests[j].HostId
+		}
+		if diff.Tests[i].AttackTechniqueId != diff.Tests[j].AttackTechniqueId {
+			return diff.Tests[i].AttackTechniqueId < diff.Tests[j].AttackTechniqueId
+		}
+		return diff.Tests[i].Name < diff.Tests[j].Name
+	})
+	return diff
+}
+
+// latestResultsByTest returns the most recent result of each test on each host, keyed by host ID and test GUID.
+func latestResultsByTest(results []TestResult) map[string]*TestResult {
+	m := make(map[string]*TestResult)
+	for i := range results {
+		result := &results[i]
+		id := result.HostId + "/" + result.Test.AutoGeneratedGuid
+		if existing, ok := m[id]; !ok || result.Time.After(existing.Time) {
+			m[id] = result
+		}
+	}
+	return m
+}
+
+func diffTestResult(a, b *TestResult) TestDiff {
+	var diff TestDiff
+	for _, result := range []*TestResult{b, a} {
+		if result != nil {
+			diff.HostId = result.HostId
+			if result.Host != nil {
+				diff.Hostname = result.Host.Hostname
+			}
+			diff.TestId = result.Test.AutoGeneratedGuid
+			diff.Name = result.Test.Name
+			diff.AttackTechniqueId = result.AttackTechniqueId
+			break
+		}
+	}
+	if a == nil {
+		diff.Change = TestChangeAdded
+		diff.StatusB, diff.ExitCodeB, diff.DurationB = b.Status, b.GetExitCode(), b.GetDuration()
+		return diff
+	}
+	if b == nil {
+		diff.Change = TestChangeRemoved
+		diff.StatusA, diff.ExitCodeA, diff.DurationA = a.Status, a.GetExitCode(), a.GetDuration()
+		return diff
+	}
+	diff.StatusA, diff.ExitCodeA, diff.DurationA = a.Status, a.GetExitCode(), a.GetDuration()
+	diff.StatusB, diff.ExitCodeB, diff.DurationB = b.Status, b.GetExitCode(), b.GetDuration()
+	diff.DurationDelta = diff.DurationB - diff.DurationA
+
+	executablesA := a.GetExecutables()
+	executablesB := b.GetExecutables()
+	for _, path := range executablesB {
+		if !slices.Contains(executablesA, path) {
+			diff.AddedExecutables = append(diff.AddedExecutables, path)
+		}
+	}
+	for _, path := range executablesA {
+		if !slices.Contains(executablesB, path) {
+			diff.RemovedExecutables = append(diff.RemovedExecutables, path)
+		}
+	}
+
+	switch {
+	case a.Status == b.Status && equalExitCodes(diff.ExitCodeA, diff.ExitCodeB) && len(diff.AddedExecutables)+len(diff.RemovedExecutables) == 0:
+		diff.Change = TestChangeUnchanged
+	case a.Status == b.Status:
+		diff.Change = TestChangeChanged
+	case b.Status.IsFailure() && !a.Status.IsFailure():
+		diff.Change = TestChangeNewlyFailing
+	case b.Status == TestStatusPassed:
+		diff.Change = TestChangeNewlyPassing
+	case b.Status == TestStatusSkipped:
+		diff.Change = TestChangeNewlySkipped
+	default:
+		diff.Change = TestChangeStatusChanged
+	}
+	return diff
+}
+
+func equalExitCodes(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,163 @@
+package atomic
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/whitfieldsdad/go-building-blocks/pkg/bb"
+)
+
+func TestDiffTestResults(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// newResult returns the result of a test that ran a single command which spawned the provided executables.
+	newResult := func(hostId, guid string, status TestStatus, exitCode int, executables ...string) TestResult {
+		var processes []bb.Process
+		for i, path := range executables {
+			processes = append(processes, bb.Process{PID: i + 1, Executable: &bb.File{Path: path}})
+		}
+		return TestResult{
+			Time:              start,
+			Status:            status,
+			Test:              Test{Name: "Test " + guid, AutoGeneratedGuid: guid},
+			AttackTechniqueId: "T1057",
+			ExecutedCommands: []ExecutedCommand{
+				{ExecutedCommand: bb.ExecutedCommand{StartTime: start, EndTime: start.Add(time.Second), ExitCode: exitCode}, Processes: processes},
+			},
+			Identity: Identity{HostId: hostId},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		a           []TestResult
+		b           []TestResult
+		want        string
+		wantAdded   []string
+		wantRemoved []string
+	}{
+		{
+			name: "unchanged",
+			a:    []TestResult{newResult("h1", "1", TestStatusPassed, 0, "/bin/ps")},
+			b:    []TestResult{newResult("h1", "1", TestStatusPassed, 0, "/bin/ps")},
+			want: TestChangeUnchanged,
+		},
+		{
+			name: "added",
+			b:    []TestResult{newResult("h1", "1", TestStatusPassed, 0)},
+			want: TestChangeAdded,
+		},
+		{
+			name: "removed",
+			a:    []TestResult{newResult("h1", "1", TestStatusPassed, 0)},
+			want: TestChangeRemoved,
+		},
+		{
+			name: "newly failing",
+			a:    []TestResult{newResult("h1", "1", TestStatusPassed, 0)},
+			b:    []TestResult{newResult("h1", "1", TestStatusFailed, 1)},
+			want: TestChangeNewlyFailing,
+		},
+		{
+			name: "newly timed out",
+			a:    []TestResult{newResult("h1", "1", TestStatusPassed, 0)},
+			b:    []TestResult{newResult("h1", "1", TestStatusTimedOut, -1)},
+			want: TestChangeNewlyFailing,
+		},
+		{
+			name: "newly passing",
+			a:    []TestResult{newResult("h1", "1", TestStatusFailed, 1)},
+			b:    []TestResult{newResult("h1", "1", TestStatusPassed, 0)},
+			want: TestChangeNewlyPassing,
+		},
+		{
+			name: "newly skipped",
+			a:    []TestResult{newResult("h1", "1", TestStatusPassed, 0)},
+			b:    []TestResult{newResult("h1", "1", TestStatusSkipped, 0)},
+			want: TestChangeNewlySkipped,
+		},
+		{
+			name: "failing differently",
+			a:    []TestResult{newResult("h1", "1", TestStatusFailed, 1)},
+			b:    []TestResult{newResult("h1", "1", TestStatusErrored, 1)},
+			want: TestChangeStatusChanged,
+		},
+		{
+			name: "exit code changed",
+			a:    []TestResult{newResult("h1", "1", TestStatusFailed, 1)},
+			b:    []TestResult{newResult("h1", "1", TestStatusFailed, 2)},
+			want: TestChangeChanged,
+		},
+		{
+			name:        "executables changed",
+			a:           []TestResult{newResult("h1", "1", TestStatusPassed, 0, "/bin/sh", "/bin/ps")},
+			b:           []TestResult{newResult("h1", "1", TestStatusPassed, 0, "/bin/sh", "/usr/bin/top")},
+			want:        TestChangeChanged,
+			wantAdded:   []string{"/usr/bin/top"},
+			wantRemoved: []string{"/bin/ps"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffTestResults(tt.a, tt.b)
+			if len(diff.Tests) != 1 {
+				t.Fatalf("expected 1 test, got %+v", diff.Tests)
+			}
+			test := diff.Tests[0]
+			if test.Change != tt.want {
+				t.Errorf("got change %s, want %s", test.Change, tt.want)
+			}
+			if test.HostId != "h1" || test.TestId != "1" || test.Name != "Test 1" {
+				t.Errorf("unexpected test: %+v", test)
+			}
+			if !slices.Equal(test.AddedExecutables, tt.wantAdded) || !slices.Equal(test.RemovedExecutables, tt.wantRemoved) {
+				t.Errorf("got added executables %v and removed executables %v, want %v and %v", test.AddedExecutables, test.RemovedExecutables, tt.wantAdded, tt.wantRemoved)
+			}
+			if len(diff.Summary) != 1 || diff.Summary[tt.want] != 1 {
+				t.Errorf("unexpected summary: %v", diff.Summary)
+			}
+			if got := diff.HasRegressions(); got != (tt.want == TestChangeNewlyFailing) {
+				t.Errorf("got regressions %t", got)
+			}
+		})
+	}
+}
+
+func TestDiffTestResultsComparesHostsSeparately(t *testing.T) {
+	result := func(hostId string, status TestStatus, offset time.Duration) TestResult {
+		return TestResult{
+			Time:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(offset),
+			Status:   status,
+			Test:     Test{Name: "Test 1", AutoGeneratedGuid: "1"},
+			Identity: Identity{HostId: hostId},
+		}
+	}
+	a := []TestResult{
+		result("h1", TestStatusPassed, 0),
+		result("h2", TestStatusFailed, 0),
+	}
+	b := []TestResult{
+		result("h2", TestStatusFailed, time.Hour),
+		result("h1", TestStatusPassed, 2*time.Hour),
+		// The most recent result from each host is used.
+		result("h2", TestStatusPassed, 2*time.Hour),
+		result("h1", TestStatusFailed, time.Hour),
+	}
+	diff := DiffTestResults(a, b)
+	if len(diff.Tests) != 2 {
+		t.Fatalf("expected 2 tests, got %+v", diff.Tests)
+	}
+	want := map[string]string{"h1": TestChangeUnchanged, "h2": TestChangeNewlyPassing}
+	for _, test := range diff.Tests {
+		if test.Change != want[test.HostId] {
+			t.Errorf("%s: got change %s, want %s", test.HostId, test.Change, want[test.HostId])
+		}
+	}
+	if diff.Tests[0].HostId != "h1" {
+		t.Errorf("expected tests to be sorted by host, got %s first", diff.Tests[0].HostId)
+	}
+	if changed := diff.GetChangedTests(); len(changed) != 1 || changed[0].HostId != "h2" {
+		t.Errorf("unexpected changed tests: %+v", changed)
+	}
+}
@@ -0,0 +1,121 @@
+package atomic
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	EnvTestInvocationDir         = "GO_ATOMIC_TEST_INVOCATION_DIR"
+	EnvTestInvocationStatusDir   = "GO_ATOMIC_TEST_INVOCATION_STATUS_DIR"
+	EnvTestInvocationResultDir   = "GO_ATOMIC_TEST_INVOCATION_RESULT_DIR"
+	EnvTestInvocationRequestDir  = "GO_ATOMIC_TEST_INVOCATION_REQUEST_DIR"
+	EnvTestInvocationResponseDir = "GO_ATOMIC_TEST_INVOCATION_RESPONSE_DIR"
+)
+
+// Directories are the directories that test invocations, their statuses, and their results are written to (see
+// docs/config/config.md).
+type Directories struct {
+	TestInvocationDir         string `json:"test_invocation_dir" yaml:"test_invocation_dir"`
+	TestInvocationStatusDir   string `json:"test_invocation_status_dir" yaml:"test_invocation_status_dir"`
+	TestInvocationResultDir   string `json:"test_invocation_result_dir" yaml:"test_invocation_result_dir"`
+	TestInvocationRequestDir  string `json:"test_invocation_request_dir" yaml:"test_invocation_request_dir"`
+	TestInvocationResponseDir string `json:"test_invocation_response_dir" yaml:"test_invocation_response_dir"`
+}
+
+// GetDirectoriesFromEnv returns the directories given by the GO_ATOMIC_TEST_INVOCATION_* environment variables, or
+// their defaults.
+func GetDirectoriesFromEnv() Directories {
+	return Directories{
+		TestInvocationDir:         getEnv(EnvTestInvocationDir, "data/test_invocations"),
+		TestInvocationStatusDir:   getEnv(EnvTestInvocationStatusDir, "data/test_invocation_statuses"),
+		TestInvocationResultDir:   getEnv(EnvTestInvocationResultDir, "data/test_invocation_results"),
+		TestInvocationRequestDir:  getEnv(EnvTestInvocationRequestDir, "data/test_invocation_requests"),
+		TestInvocationResponseDir: getEnv(EnvTestInvocationResponseDir, "data/test_invocation_responses"),
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// DirectoryEventSink writes each event to its own JSON file, named after the event's ID. Test invocations are written
+// to the invocation directory, test and process status events to the status directory, and test results to the result
+// directory.
+type DirectoryEventSink struct {
+	Directories Directories
+}
+
+func NewDirectoryEventSink(dirs Directories) *DirectoryEventSink {
+	return &DirectoryEventSink{Directories: dirs}
+}
+
+func (s *DirectoryEventSink) Emit(event Event) error {
+	var dir string
+	switch event.GetEventType() {
+	case EventTypeTestInvocation:
+		dir = s.Directories.TestInvocationDir
+	case EventTypeTestStatus, EventTypeProcessStatus:
+		dir = s.Directories.TestInvocationStatusDir
+	case EventTypeTestResult:
+		dir = s.Directories.TestInvocationResultDir
+	case EventTypeTestInvocationResponse:
+		dir = s.Directories.TestInvocationResponseDir
+	default:
+		return errors.Errorf("unsupported event type: %s", event.GetEventType())
+	}
+	return writeJSONFile(dir, event.GetEventHeader().Id, event)
+}
+
+// writeJSONFile writes a JSON document to a directory, creating the directory if necessary.
+func writeJSONFile(dir, id string, v interface{}) error {
+	if id == "" {
+		return errors.New("document has no ID")
+	}
+	err := validateId(id)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return errors.Wrap(err, "failed to create directory")
+	}
+	blob, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, id+".json"), blob)
+}
+
+// writeFileAtomic writes to a temporary file in the same directory and then renames it, so that anyone watching the
+// directory never sees a partially written file. Temporary files start with a dot so that they're easy to ignore.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+	return nil
+}
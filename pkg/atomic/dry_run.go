@@ -0,0 +1,99 @@
+package atomic
+
+import (
+	"errors"
+
+	"github.com/charmbracelet/log"
+)
+
+// DryRunResult describes what would happen if a test were run, without running it.
+type DryRunResult struct {
+	Test           Test                   `json:"test" yaml:"test"`
+	Runnable       bool                   `json:"runnable" yaml:"runnable"`
+	Reason         string                 `json:"reason,omitempty" yaml:"reason,omitempty"`
+	InputArguments map[string]interface{} `json:"input_arguments,omitempty" yaml:"input_arguments,omitempty"`
+	Dependencies   []DryRunDependency     `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+	Executor       DryRunExecutor         `json:"executor" yaml:"executor"`
+	Command        string                 `json:"command" yaml:"command"`
+	CleanupCommand string                 `json:"cleanup_command,omitempty" yaml:"cleanup_command,omitempty"`
+}
+
+// DryRunDependency describes the commands that would be used to check and resolve a dependency.
+type DryRunDependency struct {
+	Description      string         `json:"description" yaml:"description"`
+	Executor         DryRunExecutor `json:"executor" yaml:"executor"`
+	PrereqCommand    string         `json:"prereq_command" yaml:"prereq_command"`
+	GetPrereqCommand string         `json:"get_prereq_command" yaml:"get_prereq_command"`
+}
+
+// DryRunExecutor describes the program that would be used to run a command.
+type DryRunExecutor struct {
+	Name string `json:"name" yaml:"name"`
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+func newDryRunExecutor(name string) DryRunExecutor {
+	path, err := GetExecutorPath(name)
+	if err != nil {
+		log.Warnf("Failed to find executor: %s", err)
+	}
+	return DryRunExecutor{Name: name, Path: path}
+}
+
+// DryRun performs the same requirement checks, input argument handling, and command preparation as Run, and returns
+// the fully interpolated commands that would be executed, without executing anything.
+//
+// Tests that would be skipped (e.g. because they require elevation) are reported as not runnable, with a reason.
+func (t Test) DryRun(atomicsDir string, opts *TestOptions) (*DryRunResult, error) {
+	if opts == nil {
+		opts = NewTestOptions()
+	}
+	result := &DryRunResult{
+		Test:     t,
+		Runnable: true,
+		Executor: newDryRunExecutor(t.Executor.Name),
+	}
+	err := t.checkRequirements()
+	if err != nil {
+		var skipped *TestSkippedError
+		if !errors.As(err, &skipped) {
+			return nil, err
+		}
+		result.Runnable = false
+		result.Reason = skipped.Reason
+	}
+	inputArguments, err := t.ValidateInputArguments(opts.InputArguments)
+	if err != nil {
+		return nil, err
+	}
+	inputArguments = t.combineArgs(inputArguments)
+	result.InputArguments = resolveArgs(inputArguments)
+
+	for _, dependency := range t.Dependencies {
+		prereqCommand, err := prepareCommand(dependency.PrereqCommand, atomicsDir, dependency.combineArgs(inputArguments))
+		if err != nil {
+			return nil, err
+		}
+		getPrereqCommand, err := prepareCommand(dependency.GetPrereqCommand, atomicsDir, dependency.combineArgs(inputArguments))
+		if err != nil {
+			return nil, err
+		}
+		result.Dependencies = append(result.Dependencies, DryRunDependency{
+			Description:      dependency.Description,
+			Executor:         newDryRunExecutor(dependency.ExecutorName),
+			PrereqCommand:    prereqCommand,
+			GetPrereqCommand: getPrereqCommand,
+		})
+	}
+	result.Command, err = prepareCommand(t.Executor.Command, atomicsDir, inputArguments)
+	if err != nil {
+		return nil, err
+	}
+	if t.Executor.CleanupCommand != "" {
+		result.CleanupCommand, err = prepareCommand(t.Executor.CleanupCommand, atomicsDir, inputArguments)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package atomic
+
+import (
+	"sync"
+
+	"github.com/charmbracelet/log"
+)
+
+// EventBroker is an event sink that fans events out to any number of subscribers (e.g. clients of the event stream
+// served by the API). Subscribers that fall behind miss events rather than holding up the tests that are running.
+type EventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+	bufferSize  int
+}
+
+func NewEventBroker(bufferSize int) *EventBroker {
+	return &EventBroker{
+		subscribers: make(map[chan Event]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+// Subscribe returns a channel of events, and a function that must be called to unsubscribe.
+func (b *EventBroker) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, b.bufferSize)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+func (b *EventBroker) Emit(event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Warnf("Dropped %s event for a slow subscriber", event.GetEventType())
+		}
+	}
+	return nil
+}
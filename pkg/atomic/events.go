@@ -0,0 +1,298 @@
+package atomic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/whitfieldsdad/go-building-blocks/pkg/bb"
+)
+
+const (
+	EventTypeTestInvocation         = "test_invocation"
+	EventTypeTestInvocationResponse = "test_invocation_response"
+	EventTypeTestStatus             = "test_status_event"
+	EventTypeProcessStatus          = "process_status_event"
+	EventTypeTestResult             = "test_result"
+)
+
+const (
+	StatusTypeStarted   = "started"
+	StatusTypeCompleted = "completed"
+	StatusTypeExited    = "exited"
+)
+
+// Event is implemented by each of the documents described in docs/events.
+type Event interface {
+	GetEventType() string
+	GetEventHeader() EventHeader
+}
+
+// EventHeader contains the fields that are common to all events.
+type EventHeader struct {
+	Id       string    `json:"id" yaml:"id"`
+	Time     time.Time `json:"time" yaml:"time"`
+	Identity `yaml:",inline"`
+}
+
+func NewEventHeader(identity Identity) EventHeader {
+	return EventHeader{
+		Id:       bb.NewUUID4(),
+		Time:     time.Now(),
+		Identity: identity,
+	}
+}
+
+func (h EventHeader) GetEventHeader() EventHeader {
+	return h
+}
+
+// TestInvocation is a request to run a test, or a record of a test being run.
+type TestInvocation struct {
+	EventHeader    `yaml:",inline"`
+	TestId         string                 `json:"test_id" yaml:"test_id"`
+	InputArguments map[string]interface{} `json:"input_arguments,omitempty" yaml:"input_arguments,omitempty"`
+	Options        TestOptions            `json:"options" yaml:"options"`
+
+	// RunId groups together the invocations that were part of the same run (e.g. of the same test plan).
+	RunId string `json:"run_id,omitempty" yaml:"run_id,omitempty"`
+}
+
+func (TestInvocation) GetEventType() string {
+	return EventTypeTestInvocation
+}
+
+// TestInvocationResponse says whether a requested test invocation was accepted, and if not, why not.
+type TestInvocationResponse struct {
+	EventHeader      `yaml:",inline"`
+	TestId           string `json:"test_id,omitempty" yaml:"test_id,omitempty"`
+	TestInvocationId string `json:"test_invocation_id" yaml:"test_invocation_id"`
+	Accepted         bool   `json:"accepted" yaml:"accepted"`
+	Reason           string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+func (TestInvocationResponse) GetEventType() string {
+	return EventTypeTestInvocationResponse
+}
+
+// TestStatusEvent records a change in the status of a test invocation (e.g. "started" or "completed").
+type TestStatusEvent struct {
+	EventHeader      `yaml:",inline"`
+	TestId           string     `json:"test_id" yaml:"test_id"`
+	TestInvocationId string     `json:"test_invocation_id" yaml:"test_invocation_id"`
+	StatusType       string     `json:"status_type" yaml:"status_type"`
+	Status           TestStatus `json:"status,omitempty" yaml:"status,omitempty"`
+	Reason           string     `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+func (TestStatusEvent) GetEventType() string {
+	return EventTypeTestStatus
+}
+
+// ProcessStatusEvent records a process being started or exiting while a test invocation is running.
+type ProcessStatusEvent struct {
+	EventHeader      `yaml:",inline"`
+	TestInvocationId string   `json:"test_invocation_id,omitempty" yaml:"test_invocation_id,omitempty"`
+	ObjectId         string   `json:"object_id" yaml:"object_id"`
+	ObjectType       string   `json:"object_type" yaml:"object_type"`
+	EventType        string   `json:"event_type" yaml:"event_type"`
+	StatusType       string   `json:"status_type" yaml:"status_type"`
+	PID              int      `json:"pid,omitempty" yaml:"pid,omitempty"`
+	PPID             int      `json:"ppid,omitempty" yaml:"ppid,omitempty"`
+	Executable       *bb.File `json:"executable,omitempty" yaml:"executable,omitempty"`
+	Command          string   `json:"command,omitempty" yaml:"command,omitempty"`
+	ExitCode         *int     `json:"exit_code,omitempty" yaml:"exit_code,omitempty"`
+}
+
+func (ProcessStatusEvent) GetEventType() string {
+	return EventTypeProcessStatus
+}
+
+// TestResultEvent records the outcome of a test invocation. Artifact IDs refer to the objects (e.g. processes) that
+// were observed while the test was running.
+type TestResultEvent struct {
+	EventHeader      `yaml:",inline"`
+	TestId           string      `json:"test_id" yaml:"test_id"`
+	TestInvocationId string      `json:"test_invocation_id" yaml:"test_invocation_id"`
+	ArtifactIds      []string    `json:"artifact_ids" yaml:"artifact_ids"`
+	Status           TestStatus  `json:"status" yaml:"status"`
+	Reason           string      `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Result           *TestResult `json:"result,omitempty" yaml:"result,omitempty"`
+}
+
+func (TestResultEvent) GetEventType() string {
+	return EventTypeTestResult
+}
+
+// EventSink receives events as they happen. Implementations must be safe for concurrent use.
+type EventSink interface {
+	Emit(event Event) error
+}
+
+// EventSinkFunc adapts a function to the EventSink interface.
+type EventSinkFunc func(event Event) error
+
+func (f EventSinkFunc) Emit(event Event) error {
+	return f(event)
+}
+
+// MultiEventSink sends each event to several sinks.
+type MultiEventSink []EventSink
+
+func (sinks MultiEventSink) Emit(event Event) error {
+	var firstErr error
+	for _, sink := range sinks {
+		err := sink.Emit(event)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// JSONLinesEventSink writes each event as a line of JSON, wrapped in an envelope that identifies the type of event.
+type JSONLinesEventSink struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+type eventEnvelope struct {
+	EventType string `json:"event_type"`
+	Event     Event  `json:"event"`
+}
+
+func NewJSONLinesEventSink(w io.Writer) *JSONLinesEventSink {
+	return &JSONLinesEventSink{w: w}
+}
+
+func (s *JSONLinesEventSink) Emit(event Event) error {
+	blob, err := json.Marshal(eventEnvelope{EventType: event.GetEventType(), Event: event})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(blob, '\n'))
+	return err
+}
+
+// eventEmitter emits the events of a single test invocation. A nil emitter discards all events.
+type eventEmitter struct {
+	sink         EventSink
+	identity     Identity
+	testId       string
+	invocationId string
+
+	mu          sync.Mutex
+	artifactIds []string
+}
+
+type eventEmitterKey struct{}
+
+func withEventEmitter(ctx context.Context, emitter *eventEmitter) context.Context {
+	return context.WithValue(ctx, eventEmitterKey{}, emitter)
+}
+
+func getEventEmitter(ctx context.Context) *eventEmitter {
+	emitter, _ := ctx.Value(eventEmitterKey{}).(*eventEmitter)
+	return emitter
+}
+
+func (e *eventEmitter) emit(event Event) {
+	if e == nil || e.sink == nil {
+		return
+	}
+	err := e.sink.Emit(event)
+	if err != nil {
+		log.Errorf("Failed to emit %s event: %s", event.GetEventType(), err)
+	}
+}
+
+func (e *eventEmitter) emitTestStatus(statusType string, result *TestResult) {
+	if e == nil {
+		return
+	}
+	event := TestStatusEvent{
+		EventHeader:      NewEventHeader(e.identity),
+		TestId:           e.testId,
+		TestInvocationId: e.invocationId,
+		StatusType:       statusType,
+	}
+	if result != nil {
+		event.Status = result.Status
+		event.Reason = result.Reason
+	}
+	e.emit(event)
+}
+
+// processStarted emits an event for a process that was started to execute a command, and returns the ID of the process
+// object.
+func (e *eventEmitter) processStarted(command string, process bb.Process) string {
+	if e == nil {
+		return ""
+	}
+	objectId := bb.NewUUID4()
+	e.mu.Lock()
+	e.artifactIds = append(e.artifactIds, objectId)
+	e.mu.Unlock()
+
+	e.emit(ProcessStatusEvent{
+		EventHeader:      NewEventHeader(e.identity),
+		TestInvocationId: e.invocationId,
+		ObjectId:         objectId,
+		ObjectType:       "process",
+		EventType:        "status",
+		StatusType:       StatusTypeStarted,
+		PID:              process.PID,
+		PPID:             process.PPID,
+		Executable:       process.Executable,
+		Command:          command,
+	})
+	return objectId
+}
+
+func (e *eventEmitter) processExited(objectId string, pid int, executedCommand *ExecutedCommand) {
+	if e == nil {
+		return
+	}
+	event := ProcessStatusEvent{
+		EventHeader:      NewEventHeader(e.identity),
+		TestInvocationId: e.invocationId,
+		ObjectId:         objectId,
+		ObjectType:       "process",
+		EventType:        "status",
+		StatusType:       StatusTypeExited,
+		PID:              pid,
+	}
+	if executedCommand != nil {
+		exitCode := executedCommand.ExitCode
+		event.ExitCode = &exitCode
+		if processes := executedCommand.GetProcesses(); len(processes) > 0 && processes[0].PID == pid {
+			event.PPID = processes[0].PPID
+			event.Executable = processes[0].Executable
+		}
+	}
+	e.emit(event)
+}
+
+func (e *eventEmitter) emitTestResult(result *TestResult) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	artifactIds := append([]string{}, e.artifactIds...)
+	e.mu.Unlock()
+
+	e.emit(TestResultEvent{
+		EventHeader:      NewEventHeader(e.identity),
+		TestId:           e.testId,
+		TestInvocationId: e.invocationId,
+		ArtifactIds:      artifactIds,
+		Status:           result.Status,
+		Reason:           result.Reason,
+		Result:           result,
+	})
+}
@@ -0,0 +1,47 @@
+package atomic
+
+import (
+	"os/exec"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// executorBinaries lists the programs that can be used to run commands for each type of executor, in order of
+// preference.
+var executorBinaries = map[string][]string{
+	"command_prompt": {"cmd.exe"},
+	"powershell":     {"powershell.exe", "pwsh.exe", "pwsh", "powershell"},
+	"sh":             {"sh"},
+	"bash":           {"bash"},
+}
+
+// GetExecutorPath returns the path to the program that will be used to run commands for the provided executor
+// (e.g. "powershell").
+func GetExecutorPath(executorName string) (string, error) {
+	binaries, ok := executorBinaries[executorName]
+	if !ok {
+		return "", errors.Errorf("unsupported executor: %s", executorName)
+	}
+	if runtime.GOOS != "windows" && executorName == "powershell" {
+		binaries = []string{"pwsh", "powershell"}
+	}
+	for _, binary := range binaries {
+		path, err := exec.LookPath(binary)
+		if err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.Errorf("executor not found: %s", executorName)
+}
+
+// getExecutorArgs returns the arguments that are passed to an executor's program to run a command.
+func getExecutorArgs(executorName, command string) []string {
+	switch executorName {
+	case "command_prompt":
+		return []string{"/c", command}
+	case "powershell":
+		return []string{"-NoProfile", "-NonInteractive", "-Command", command}
+	}
+	return []string{"-c", command}
+}
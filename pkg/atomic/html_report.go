@@ -0,0 +1,151 @@
+package atomic
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+var htmlReportFuncs = template.FuncMap{
+	"processTree": GetProcessTree,
+	"formatTime": func(t time.Time) string {
+		return t.Local().Format(time.DateTime)
+	},
+	"formatDuration": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	},
+	"statusClass": func(status TestStatus) string {
+		if status.IsFailure() {
+			return "failed"
+		}
+		return string(status)
+	},
+	"trim": strings.TrimSpace,
+	"exitCode": func(exitCode *int) string {
+		if exitCode == nil {
+			return ""
+		}
+		return fmt.Sprint(*exitCode)
+	},
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(htmlReportFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1, h2, h3 { font-weight: 600; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f3f3f3; }
+pre { background: #f6f8fa; padding: 8px; overflow-x: auto; white-space: pre-wrap; word-break: break-all; }
+details { margin-bottom: 0.5em; }
+summary { cursor: pointer; }
+code { font-size: 0.9em; }
+.passed { background: #8ec843; }
+.failed { background: #e60d0d; color: #fff; }
+.mixed { background: #ffe766; }
+.skipped { background: #ccc; }
+.badge { padding: 1px 6px; border-radius: 3px; font-size: 0.85em; }
+.matrix td { min-width: 12em; }
+.matrix a { color: inherit; text-decoration: none; }
+.technique { display: block; margin: 2px 0; padding: 2px 4px; }
+ul.tree { margin: 0; padding-left: 1.5em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+<tr><th>Generated</th><td>{{formatTime .GeneratedAt}}</td></tr>
+<tr><th>Started</th><td>{{formatTime .StartTime}}</td></tr>
+<tr><th>Duration</th><td>{{formatDuration .GetDuration}}</td></tr>
+<tr><th>Hosts</th><td>{{range $i, $host := .Hosts}}{{if $i}}, {{end}}{{$host}}{{end}}</td></tr>
+</table>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Tests</th><th>Passed</th><th>Failed</th><th>Skipped</th><th>Errored</th><th>Timed out</th></tr>
+<tr><td>{{.Summary.Total}}</td><td>{{.Summary.Passed}}</td><td>{{.Summary.Failed}}</td><td>{{.Summary.Skipped}}</td><td>{{.Summary.Errored}}</td><td>{{.Summary.TimedOut}}</td></tr>
+</table>
+
+<h3>By tactic</h3>
+<table>
+<tr><th>Tactic</th><th>Techniques</th><th>Tests</th><th>Passed</th><th>Failed</th><th>Skipped</th><th>Errored</th><th>Timed out</th></tr>
+{{- range .Tactics}}
+<tr><td>{{.Name}}</td><td>{{len .Techniques}}</td><td>{{.Summary.Total}}</td><td>{{.Summary.Passed}}</td><td>{{.Summary.Failed}}</td><td>{{.Summary.Skipped}}</td><td>{{.Summary.Errored}}</td><td>{{.Summary.TimedOut}}</td></tr>
+{{- end}}
+</table>
+
+<h3>By technique</h3>
+<table>
+<tr><th>Technique</th><th>Name</th><th>Status</th><th>Tests</th><th>Passed</th><th>Failed</th><th>Skipped</th><th>Errored</th><th>Timed out</th></tr>
+{{- range .Techniques}}
+<tr><td><a href="{{.GetURL}}">{{.Id}}</a></td><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Summary.Total}}</td><td>{{.Summary.Passed}}</td><td>{{.Summary.Failed}}</td><td>{{.Summary.Skipped}}</td><td>{{.Summary.Errored}}</td><td>{{.Summary.TimedOut}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Matrix</h2>
+<table class="matrix">
+<tr>{{range .Tactics}}<th>{{.Name}}</th>{{end}}</tr>
+<tr>{{range .Tactics}}<td>{{range .Techniques}}<a class="technique {{.Status}}" href="#technique-{{.Id}}" title="{{.Summary.Passed}} passed, {{.Summary.Failed}} failed, {{.Summary.Skipped}} skipped">{{.Id}} {{.Name}}</a>{{end}}</td>{{end}}</tr>
+</table>
+<p><span class="badge passed">passed</span> <span class="badge failed">failed</span> <span class="badge mixed">mixed</span> <span class="badge skipped">skipped</span></p>
+
+<h2>Tests</h2>
+{{- $techniqueId := "-"}}
+{{- range .Results}}
+{{- if ne .AttackTechniqueId $techniqueId}}
+{{- $techniqueId = .AttackTechniqueId}}
+<h3 id="technique-{{.AttackTechniqueId}}">{{.AttackTechniqueId}}: {{.AttackTechniqueName}}</h3>
+{{- end}}
+<details>
+<summary><span class="badge {{statusClass .Status}}">{{.Status}}</span> {{.Test.Name}} <code>{{.Test.AutoGeneratedGuid}}</code></summary>
+<table>
+<tr><th>Result ID</th><td><code>{{.Id}}</code></td></tr>
+{{- if .RunId}}<tr><th>Run ID</th><td><code>{{.RunId}}</code></td></tr>{{end}}
+<tr><th>Time</th><td>{{formatTime .Time}}</td></tr>
+<tr><th>Duration</th><td>{{formatDuration .GetDuration}}</td></tr>
+{{- if .Host}}<tr><th>Host</th><td>{{.Host.Hostname}} ({{.Host.OS}})</td></tr>{{end}}
+{{- if .User}}<tr><th>User</th><td>{{.User.Username}}{{if .User.Elevated}} (elevated){{end}}</td></tr>{{end}}
+{{- if .GetExitCode}}<tr><th>Exit code</th><td>{{exitCode .GetExitCode}}</td></tr>{{end}}
+{{- if .Reason}}<tr><th>Reason</th><td>{{.Reason}}</td></tr>{{end}}
+</table>
+{{- range .Dependencies}}
+<h4>Dependency: {{trim .Dependency.Description}} ({{if .Met}}met{{else}}not met{{end}})</h4>
+{{- range .ExecutedCommands}}{{template "command" .}}{{end}}
+{{- end}}
+{{- range $i, $command := .ExecutedCommands}}
+<h4>{{if $i}}Cleanup command{{else}}Test command{{end}}</h4>
+{{- template "command" $command}}
+{{- end}}
+</details>
+{{- end}}
+</body>
+</html>
+{{define "command"}}
+<pre>$ {{trim .Command.Command}}</pre>
+<p>Exit code: {{.ExitCode}} &middot; {{formatTime .StartTime}} &middot; {{formatDuration (.EndTime.Sub .StartTime)}}</p>
+{{- if .Stdout}}
+<details><summary>stdout</summary><pre>{{.Stdout}}</pre></details>
+{{- end}}
+{{- if .Stderr}}
+<details open><summary>stderr</summary><pre>{{.Stderr}}</pre></details>
+{{- end}}
+{{- with processTree .}}
+<details><summary>Processes</summary>
+<ul class="tree">{{range .}}{{template "process" .}}{{end}}</ul>
+</details>
+{{- end}}
+{{- end}}
+{{define "process"}}<li><code>{{.PID}}</code> {{with .Executable}}{{.Path}}{{with .Hashes}}<br><small>MD5: <code>{{.MD5}}</code><br>SHA-1: <code>{{.SHA1}}</code><br>SHA-256: <code>{{.SHA256}}</code></small>{{end}}{{end}}
+{{- if .Children}}<ul class="tree">{{range .Children}}{{template "process" .}}{{end}}</ul>{{end}}</li>{{end}}
+`))
+
+// WriteHTMLReport writes a report as a single HTML file with no external dependencies.
+func WriteHTMLReport(w io.Writer, report *Report) error {
+	return htmlReportTemplate.Execute(w, report)
+}
@@ -0,0 +1,177 @@
+package atomic
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/denisbrodbeck/machineid"
+	"github.com/elastic/go-sysinfo"
+	"github.com/pkg/errors"
+	"github.com/whitfieldsdad/go-building-blocks/pkg/bb"
+)
+
+const (
+	appId = "go-atomic-red-team"
+
+	EnvAgentIdPath = "GO_ATOMIC_AGENT_ID_PATH"
+)
+
+var (
+	identityOnce sync.Once
+	identity     Identity
+)
+
+// Identity identifies the agent, host, and user that events and results originate from.
+type Identity struct {
+	AgentId string    `json:"agent_id,omitempty" yaml:"agent_id,omitempty"`
+	HostId  string    `json:"host_id,omitempty" yaml:"host_id,omitempty"`
+	UserId  string    `json:"user_id,omitempty" yaml:"user_id,omitempty"`
+	Host    *HostInfo `json:"host,omitempty" yaml:"host,omitempty"`
+	User    *UserInfo `json:"user,omitempty" yaml:"user,omitempty"`
+}
+
+// HostInfo describes the host that a test was run on.
+type HostInfo struct {
+	Hostname      string   `json:"hostname" yaml:"hostname"`
+	OS            string   `json:"os" yaml:"os"`
+	OSFamily      string   `json:"os_family,omitempty" yaml:"os_family,omitempty"`
+	OSPlatform    string   `json:"os_platform,omitempty" yaml:"os_platform,omitempty"`
+	OSName        string   `json:"os_name,omitempty" yaml:"os_name,omitempty"`
+	OSVersion     string   `json:"os_version,omitempty" yaml:"os_version,omitempty"`
+	KernelVersion string   `json:"kernel_version,omitempty" yaml:"kernel_version,omitempty"`
+	Architecture  string   `json:"architecture" yaml:"architecture"`
+	IPs           []string `json:"ips,omitempty" yaml:"ips,omitempty"`
+}
+
+// UserInfo describes the user that a test was run as. On Windows, the ID is a SID.
+type UserInfo struct {
+	Id       string `json:"id" yaml:"id"`
+	Username string `json:"username" yaml:"username"`
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Elevated bool   `json:"elevated" yaml:"elevated"`
+}
+
+// GetIdentity returns the identity of this agent, host, and user. The identity is only looked up once per process.
+func GetIdentity() Identity {
+	identityOnce.Do(func() {
+		identity = *NewIdentity()
+	})
+	return identity
+}
+
+// NewIdentity looks up the identity of this agent, host, and user. If the agent ID can't be persisted, a random agent
+// ID is used instead, and any host or user information that can't be looked up is left out.
+func NewIdentity() *Identity {
+	identity := &Identity{}
+	hostId, err := GetHostId()
+	if err != nil {
+		log.Warnf("Failed to get host ID: %s", err)
+	}
+	identity.HostId = hostId
+
+	agentId, err := GetAgentId(GetAgentIdPath())
+	if err != nil {
+		log.Warnf("Failed to get agent ID - using a random agent ID instead: %s", err)
+		agentId = bb.NewUUID4()
+	}
+	identity.AgentId = agentId
+
+	identity.Host, err = GetHostInfo()
+	if err != nil {
+		log.Warnf("Failed to get host information: %s", err)
+	}
+	identity.User, err = GetUserInfo()
+	if err != nil {
+		log.Warnf("Failed to get user information: %s", err)
+	} else {
+		identity.UserId = identity.User.Id
+	}
+	return identity
+}
+
+// GetHostId returns a stable ID for this host that is derived from its machine ID. The machine ID itself is not
+// disclosed.
+func GetHostId() (string, error) {
+	return machineid.ProtectedID(appId)
+}
+
+// GetAgentIdPath returns the path to the file that the agent ID is persisted to. It can be set using the
+// GO_ATOMIC_AGENT_ID_PATH environment variable.
+func GetAgentIdPath() string {
+	path := os.Getenv(EnvAgentIdPath)
+	if path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, appId, "agent_id")
+}
+
+// GetAgentId reads the agent ID from the provided file, or generates a new agent ID and writes it to the file if the
+// file doesn't exist yet.
+func GetAgentId(path string) (string, error) {
+	blob, err := os.ReadFile(path)
+	if err == nil {
+		agentId := strings.TrimSpace(string(blob))
+		if agentId != "" {
+			return agentId, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	agentId := bb.NewUUID4()
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create directory")
+	}
+	err = writeFileAtomic(path, []byte(agentId+"\n"))
+	if err != nil {
+		return "", err
+	}
+	return agentId, nil
+}
+
+func GetHostInfo() (*HostInfo, error) {
+	host, err := sysinfo.Host()
+	if err != nil {
+		return nil, err
+	}
+	info := host.Info()
+	hostInfo := &HostInfo{
+		Hostname:      info.Hostname,
+		KernelVersion: info.KernelVersion,
+		Architecture:  info.Architecture,
+		IPs:           info.IPs,
+	}
+	if info.OS != nil {
+		hostInfo.OS = info.OS.Type
+		hostInfo.OSFamily = info.OS.Family
+		hostInfo.OSPlatform = info.OS.Platform
+		hostInfo.OSName = info.OS.Name
+		hostInfo.OSVersion = info.OS.Version
+	}
+	return hostInfo, nil
+}
+
+func GetUserInfo() (*UserInfo, error) {
+	u, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	elevated, err := bb.IsElevated()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check if current process is elevated")
+	}
+	return &UserInfo{
+		Id:       u.Uid,
+		Username: u.Username,
+		Name:     u.Name,
+		Elevated: elevated,
+	}, nil
+}
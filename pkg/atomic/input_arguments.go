@@ -0,0 +1,258 @@
+package atomic
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// inputArgumentPattern matches references to input arguments (e.g. "#{output_file}").
+	inputArgumentPattern = regexp.MustCompile(`#\{([\w\-]+)\}`)
+
+	// atomicsFolderPattern matches references to the atomics folder (e.g. "PathToAtomicsFolder" or "$PathToAtomicsFolder").
+	atomicsFolderPattern = regexp.MustCompile(`\$?PathToAtomics(Folder|Dir)`)
+)
+
+// UnresolvedInputArgumentsError is returned when a command still references input arguments after interpolation.
+type UnresolvedInputArgumentsError struct {
+	Names []string `json:"names" yaml:"names"`
+}
+
+func (e UnresolvedInputArgumentsError) Error() string {
+	return fmt.Sprintf("unresolved input arguments: %s", strings.Join(e.Names, ", "))
+}
+
+func prepareCommand(command, atomicsDir string, inputArguments map[string]interface{}) (string, error) {
+	if inputArguments != nil {
+		command = interpolateArgs(command, resolveArgs(inputArguments))
+	}
+	command, err := patchAtomicsDir(command, atomicsDir)
+	if err != nil {
+		return "", err
+	}
+	unresolved := getUnresolvedArgs(command, inputArguments)
+	if len(unresolved) > 0 {
+		return "", &UnresolvedInputArgumentsError{Names: unresolved}
+	}
+	return command, nil
+}
+
+func patchAtomicsDir(command, atomicsDir string) (string, error) {
+	if atomicsDir == "" {
+		return command, nil
+	}
+	atomicsDir = strings.ReplaceAll(atomicsDir, "\\", "\\\\")
+	return atomicsFolderPattern.ReplaceAllLiteralString(command, atomicsDir), nil
+}
+
+func interpolateArgs(command string, inputArguments map[string]interface{}) string {
+	for k, v := range inputArguments {
+		value := fmt.Sprint(v)
+		command = strings.ReplaceAll(command, fmt.Sprintf("#{%s}", k), value)
+		command = strings.ReplaceAll(command, fmt.Sprintf("{{%s}}", k), value)
+	}
+	return command
+}
+
+// resolveArgs interpolates input arguments whose values reference other input arguments (e.g. a default value of
+// "#{output_dir}\out.txt"). Circular references are left unresolved.
+func resolveArgs(inputArguments map[string]interface{}) map[string]interface{} {
+	resolved := make(map[string]interface{}, len(inputArguments))
+	for k, v := range inputArguments {
+		resolved[k] = v
+	}
+	for i := 0; i < len(resolved); i++ {
+		changed := false
+		for k, v := range resolved {
+			s, ok := v.(string)
+			if !ok || !inputArgumentPattern.MatchString(s) || strings.Contains(s, fmt.Sprintf("#{%s}", k)) {
+				continue
+			}
+			interpolated := interpolateArgs(s, resolved)
+			if interpolated != s {
+				resolved[k] = interpolated
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return resolved
+}
+
+// getUnresolvedArgs returns the names of any input arguments that are still referenced by a command. Commands can
+// contain "#{...}" for other reasons (e.g. string interpolation in Ruby, or comments in PowerShell), so references to
+// names that aren't input arguments are left as-is.
+func getUnresolvedArgs(command string, inputArguments map[string]interface{}) []string {
+	var names []string
+	for _, match := range inputArgumentPattern.FindAllStringSubmatch(command, -1) {
+		if _, ok := inputArguments[match[1]]; ok && !slices.Contains(names, match[1]) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+func combineArgs(defaultArguments map[string]ArgSpec, inputArguments map[string]interface{}) map[string]interface{} {
+	m := getArgMap(defaultArguments)
+	for k, v := range inputArguments {
+		m[k] = v
+	}
+	return m
+}
+
+func getArgMap(args map[string]ArgSpec) map[string]interface{} {
+	m := make(map[string]interface{})
+	for k, argspec := range args {
+		v := argspec.DefaultValue
+		m[k] = v
+	}
+	return m
+}
+
+// InputArgumentError describes an input argument that failed validation.
+type InputArgumentError struct {
+	TestId string      `json:"test_id,omitempty" yaml:"test_id,omitempty"`
+	Name   string      `json:"name" yaml:"name"`
+	Value  interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+	Reason string      `json:"reason" yaml:"reason"`
+}
+
+func (e InputArgumentError) Error() string {
+	if e.TestId != "" {
+		return fmt.Sprintf("%s (test ID: %s): %s", e.Name, e.TestId, e.Reason)
+	}
+	return fmt.Sprintf("%s: %s", e.Name, e.Reason)
+}
+
+// InputArgumentsError is returned when one or more input arguments fail validation.
+type InputArgumentsError struct {
+	Errors []InputArgumentError `json:"errors" yaml:"errors"`
+}
+
+func (e InputArgumentsError) Error() string {
+	var reasons []string
+	for _, err := range e.Errors {
+		reasons = append(reasons, err.Error())
+	}
+	return fmt.Sprintf("invalid input arguments: %s", strings.Join(reasons, "; "))
+}
+
+// ValidateInputArguments checks the provided input arguments against the test's argument specifications and returns
+// a copy with each value coerced to the type of its argument (e.g. "5" to 5 for integers).
+func (t Test) ValidateInputArguments(inputArguments map[string]interface{}) (map[string]interface{}, error) {
+	validated := make(map[string]interface{}, len(inputArguments))
+	var errs []InputArgumentError
+	for _, name := range getSortedKeys(inputArguments) {
+		value := inputArguments[name]
+		argspec, ok := t.InputArguments[name]
+		if !ok {
+			errs = append(errs, InputArgumentError{TestId: t.AutoGeneratedGuid, Name: name, Value: value, Reason: "unknown input argument"})
+			continue
+		}
+		coerced, err := coerceArg(argspec.Type, value)
+		if err != nil {
+			errs = append(errs, InputArgumentError{TestId: t.AutoGeneratedGuid, Name: name, Value: value, Reason: err.Error()})
+			continue
+		}
+		validated[name] = coerced
+	}
+	if len(errs) > 0 {
+		return nil, &InputArgumentsError{Errors: errs}
+	}
+	return validated, nil
+}
+
+func coerceArg(argType string, value interface{}) (interface{}, error) {
+	switch strings.ToLower(argType) {
+	case "integer", "int":
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case int64:
+			return int(v), nil
+		case float64:
+			if v != math.Trunc(v) {
+				return nil, errors.Errorf("expected an integer, got %v", v)
+			}
+			return int(v), nil
+		case string:
+			i, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return nil, errors.Errorf("expected an integer, got %q", v)
+			}
+			return i, nil
+		}
+		return nil, errors.Errorf("expected an integer, got %T", value)
+	case "float":
+		switch v := value.(type) {
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, errors.Errorf("expected a float, got %q", v)
+			}
+			return f, nil
+		}
+		return nil, errors.Errorf("expected a float, got %T", value)
+	case "bool", "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, errors.Errorf("expected a boolean, got %q", v)
+			}
+			return b, nil
+		}
+		return nil, errors.Errorf("expected a boolean, got %T", value)
+	case "url":
+		s, ok := value.(string)
+		if !ok {
+			return nil, errors.Errorf("expected a URL, got %T", value)
+		}
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme == "" {
+			return nil, errors.Errorf("expected a URL, got %q", s)
+		}
+		return s, nil
+	case "path":
+		s, ok := value.(string)
+		if !ok {
+			return nil, errors.Errorf("expected a path, got %T", value)
+		}
+		if strings.TrimSpace(s) == "" {
+			return nil, errors.New("expected a path, got an empty string")
+		}
+		return s, nil
+	default:
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, errors.Errorf("expected a string, got %T", value)
+		}
+		return fmt.Sprint(value), nil
+	}
+}
+
+func getSortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,328 @@
+package atomic
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPrepareCommand(t *testing.T) {
+	tests := []struct {
+		name           string
+		command        string
+		atomicsDir     string
+		inputArguments map[string]interface{}
+		want           string
+	}{
+		{
+			name:           "interpolates input arguments",
+			command:        "echo #{message} > #{output_file}",
+			inputArguments: map[string]interface{}{"message": "hello", "output_file": "/tmp/out.txt"},
+			want:           "echo hello > /tmp/out.txt",
+		},
+		{
+			name:           "interpolates repeated references",
+			command:        "touch #{path} && rm #{path}",
+			inputArguments: map[string]interface{}{"path": "/tmp/x"},
+			want:           "touch /tmp/x && rm /tmp/x",
+		},
+		{
+			name:           "interpolates legacy references",
+			command:        "echo {{message}}",
+			inputArguments: map[string]interface{}{"message": "hello"},
+			want:           "echo hello",
+		},
+		{
+			name:           "interpolates non-string values",
+			command:        "head -n #{count} #{path}",
+			inputArguments: map[string]interface{}{"count": 5, "path": "/etc/passwd"},
+			want:           "head -n 5 /etc/passwd",
+		},
+		{
+			name:           "interpolates input arguments that reference other input arguments",
+			command:        "cat #{output_file}",
+			inputArguments: map[string]interface{}{"output_dir": "/tmp", "output_file": "#{output_dir}/out.txt"},
+			want:           "cat /tmp/out.txt",
+		},
+		{
+			name:       "rewrites PathToAtomicsFolder",
+			command:    "PathToAtomicsFolder/T1057/src/a.sh",
+			atomicsDir: "/opt/atomics",
+			want:       "/opt/atomics/T1057/src/a.sh",
+		},
+		{
+			name:       "rewrites $PathToAtomicsFolder and PathToAtomicsDir",
+			command:    "$PathToAtomicsFolder/T1057/src/a.ps1 PathToAtomicsDir/T1057/bin/b.exe",
+			atomicsDir: "/opt/atomics",
+			want:       "/opt/atomics/T1057/src/a.ps1 /opt/atomics/T1057/bin/b.exe",
+		},
+		{
+			name:       "escapes backslashes in the atomics directory",
+			command:    "PathToAtomicsFolder\\T1057\\src\\a.ps1",
+			atomicsDir: `C:\AtomicRedTeam\atomics`,
+			want:       `C:\\AtomicRedTeam\\atomics\T1057\src\a.ps1`,
+		},
+		{
+			name:           "rewrites PathToAtomicsFolder in input arguments",
+			command:        "#{script}",
+			atomicsDir:     "/opt/atomics",
+			inputArguments: map[string]interface{}{"script": "PathToAtomicsFolder/T1057/src/a.sh"},
+			want:           "/opt/atomics/T1057/src/a.sh",
+		},
+		{
+			name:    "leaves PathToAtomicsFolder if no atomics directory is provided",
+			command: "PathToAtomicsFolder/T1057/src/a.sh",
+			want:    "PathToAtomicsFolder/T1057/src/a.sh",
+		},
+		{
+			name:           "leaves references to names that aren't input arguments",
+			command:        `ruby -e 'name = "#{user}"; puts "hello #{name}"'`,
+			inputArguments: map[string]interface{}{"user": "root"},
+			want:           `ruby -e 'name = "root"; puts "hello #{name}"'`,
+		},
+		{
+			name:    "leaves references if there are no input arguments",
+			command: "Write-Host \"#{message}\"",
+			want:    "Write-Host \"#{message}\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prepareCommand(tt.command, tt.atomicsDir, tt.inputArguments)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrepareCommandUnresolvedInputArguments(t *testing.T) {
+	tests := []struct {
+		name           string
+		command        string
+		inputArguments map[string]interface{}
+		want           []string
+	}{
+		{
+			name:           "self reference",
+			command:        "echo #{message} > #{output_file}",
+			inputArguments: map[string]interface{}{"message": "hello #{message}", "output_file": "/tmp/out.txt"},
+			want:           []string{"message"},
+		},
+		{
+			name:           "repeated self reference",
+			command:        "touch #{path} && rm #{path} && echo #{other}",
+			inputArguments: map[string]interface{}{"path": "#{path}"},
+			want:           []string{"path"},
+		},
+		{
+			// Which of the input arguments is left unresolved depends on the order in which they're resolved.
+			name:           "circular reference",
+			command:        "echo #{a}",
+			inputArguments: map[string]interface{}{"a": "#{b}", "b": "#{a}"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := prepareCommand(tt.command, "", tt.inputArguments)
+			var unresolved *UnresolvedInputArgumentsError
+			if !errors.As(err, &unresolved) {
+				t.Fatalf("expected an UnresolvedInputArgumentsError, got %v", err)
+			}
+			if tt.want != nil && !reflect.DeepEqual(unresolved.Names, tt.want) {
+				t.Errorf("got %v, want %v", unresolved.Names, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		inputArguments map[string]interface{}
+		want           map[string]interface{}
+	}{
+		{
+			name:           "no references",
+			inputArguments: map[string]interface{}{"a": "1", "b": 2},
+			want:           map[string]interface{}{"a": "1", "b": 2},
+		},
+		{
+			name:           "single reference",
+			inputArguments: map[string]interface{}{"dir": "/tmp", "file": "#{dir}/out.txt"},
+			want:           map[string]interface{}{"dir": "/tmp", "file": "/tmp/out.txt"},
+		},
+		{
+			name:           "chained references",
+			inputArguments: map[string]interface{}{"a": "#{b}/a", "b": "#{c}/b", "c": "/c"},
+			want:           map[string]interface{}{"a": "/c/b/a", "b": "/c/b", "c": "/c"},
+		},
+		{
+			name:           "self reference",
+			inputArguments: map[string]interface{}{"a": "#{a}"},
+			want:           map[string]interface{}{"a": "#{a}"},
+		},
+		{
+			name:           "reference to an unknown input argument",
+			inputArguments: map[string]interface{}{"a": "#{unknown}"},
+			want:           map[string]interface{}{"a": "#{unknown}"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveArgs(tt.inputArguments)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveArgsDoesNotModifyInput(t *testing.T) {
+	inputArguments := map[string]interface{}{"dir": "/tmp", "file": "#{dir}/out.txt"}
+	resolveArgs(inputArguments)
+	if inputArguments["file"] != "#{dir}/out.txt" {
+		t.Errorf("input arguments were modified: %v", inputArguments)
+	}
+}
+
+func TestCoerceArg(t *testing.T) {
+	tests := []struct {
+		argType string
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{argType: "path", value: "/tmp/out.txt", want: "/tmp/out.txt"},
+		{argType: "Path", value: `C:\Windows\Temp\out.txt`, want: `C:\Windows\Temp\out.txt`},
+		{argType: "path", value: "  ", wantErr: true},
+		{argType: "path", value: 1, wantErr: true},
+		{argType: "integer", value: 5, want: 5},
+		{argType: "integer", value: int64(5), want: 5},
+		{argType: "integer", value: float64(5), want: 5},
+		{argType: "integer", value: " 5 ", want: 5},
+		{argType: "int", value: "-1", want: -1},
+		{argType: "integer", value: 5.5, wantErr: true},
+		{argType: "integer", value: "five", wantErr: true},
+		{argType: "integer", value: true, wantErr: true},
+		{argType: "float", value: 1, want: float64(1)},
+		{argType: "float", value: int64(1), want: float64(1)},
+		{argType: "float", value: 1.5, want: 1.5},
+		{argType: "float", value: "1.5", want: 1.5},
+		{argType: "float", value: "one", wantErr: true},
+		{argType: "float", value: []interface{}{1.5}, wantErr: true},
+		{argType: "string", value: "hello", want: "hello"},
+		{argType: "string", value: 5, want: "5"},
+		{argType: "string", value: true, want: "true"},
+		{argType: "", value: "hello", want: "hello"},
+		{argType: "string", value: map[string]interface{}{"a": "b"}, wantErr: true},
+		{argType: "string", value: []interface{}{"a"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := coerceArg(tt.argType, tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("coerceArg(%q, %#v): expected an error, got %#v", tt.argType, tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("coerceArg(%q, %#v): unexpected error: %s", tt.argType, tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("coerceArg(%q, %#v): got %#v, want %#v", tt.argType, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidateInputArguments(t *testing.T) {
+	test := Test{
+		AutoGeneratedGuid: "4ff64f0b-aaf2-4866-b39d-38d9791407cc",
+		InputArguments: map[string]ArgSpec{
+			"output_file": {Type: "path", DefaultValue: "/tmp/out.txt"},
+			"count":       {Type: "integer", DefaultValue: "3"},
+			"ratio":       {Type: "float", DefaultValue: "0.5"},
+			"message":     {Type: "string", DefaultValue: "hello"},
+		},
+	}
+	tests := []struct {
+		name           string
+		inputArguments map[string]interface{}
+		want           map[string]interface{}
+		wantErrs       []InputArgumentError
+	}{
+		{
+			name:           "coerces input arguments",
+			inputArguments: map[string]interface{}{"output_file": "/tmp/x.txt", "count": "5", "ratio": "1.5", "message": 42},
+			want:           map[string]interface{}{"output_file": "/tmp/x.txt", "count": 5, "ratio": 1.5, "message": "42"},
+		},
+		{
+			name:           "missing input arguments are left to their defaults",
+			inputArguments: map[string]interface{}{"count": 5},
+			want:           map[string]interface{}{"count": 5},
+		},
+		{
+			name:           "no input arguments",
+			inputArguments: nil,
+			want:           map[string]interface{}{},
+		},
+		{
+			name:           "unknown input argument",
+			inputArguments: map[string]interface{}{"count": 5, "cuont": 5},
+			wantErrs: []InputArgumentError{
+				{TestId: test.AutoGeneratedGuid, Name: "cuont", Value: 5, Reason: "unknown input argument"},
+			},
+		},
+		{
+			name:           "invalid input arguments",
+			inputArguments: map[string]interface{}{"count": "five", "output_file": ""},
+			wantErrs: []InputArgumentError{
+				{TestId: test.AutoGeneratedGuid, Name: "count", Value: "five", Reason: `expected an integer, got "five"`},
+				{TestId: test.AutoGeneratedGuid, Name: "output_file", Value: "", Reason: "expected a path, got an empty string"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := test.ValidateInputArguments(tt.inputArguments)
+			if tt.wantErrs != nil {
+				var inputArgumentsErr *InputArgumentsError
+				if !errors.As(err, &inputArgumentsErr) {
+					t.Fatalf("expected an InputArgumentsError, got %v", err)
+				}
+				if !reflect.DeepEqual(inputArgumentsErr.Errors, tt.wantErrs) {
+					t.Errorf("got %+v, want %+v", inputArgumentsErr.Errors, tt.wantErrs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateInputArgumentsDefaults(t *testing.T) {
+	test := Test{
+		InputArguments: map[string]ArgSpec{
+			"output_file": {Type: "path", DefaultValue: "/tmp/out.txt"},
+			"count":       {Type: "integer", DefaultValue: "3"},
+		},
+	}
+	validated, err := test.ValidateInputArguments(map[string]interface{}{"count": "5"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := combineArgs(test.InputArguments, validated)
+	want := map[string]interface{}{"output_file": "/tmp/out.txt", "count": 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,207 @@
+package atomic
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JUnitTestSuites is the root element of a JUnit XML report. Each ATT&CK technique is a test suite, and each test is a
+// test case.
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr,omitempty"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+type JUnitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       float64         `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Hostname   string          `xml:"hostname,attr,omitempty"`
+	Properties []JUnitProperty `xml:"properties>property,omitempty"`
+	Cases      []JUnitTestCase `xml:"testcase"`
+}
+
+type JUnitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *JUnitMessage `xml:"failure,omitempty"`
+	Error     *JUnitMessage `xml:"error,omitempty"`
+	Skipped   *JUnitMessage `xml:"skipped,omitempty"`
+	SystemOut *JUnitOutput  `xml:"system-out,omitempty"`
+	SystemErr *JUnitOutput  `xml:"system-err,omitempty"`
+}
+
+// JUnitOutput is written as CDATA so that the output of commands stays readable.
+type JUnitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+func newJUnitOutput(s string) *JUnitOutput {
+	if s == "" {
+		return nil
+	}
+	// Remove characters that aren't allowed in XML documents (e.g. terminal escape sequences).
+	s = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || (r >= 0x20 && r != 0xFFFE && r != 0xFFFF) {
+			return r
+		}
+		return -1
+	}, s)
+	return &JUnitOutput{Text: s}
+}
+
+type JUnitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// NewJUnitReport converts test results into a JUnit XML report. Failed and timed out tests are reported as failures,
+// tests that couldn't be run are reported as errors, and skipped tests are reported as skipped along with the reason.
+func NewJUnitReport(name string, results []TestResult) JUnitTestSuites {
+	report := JUnitTestSuites{Name: name}
+	suites := make(map[string]*JUnitTestSuite)
+	var suiteNames []string
+	for _, result := range results {
+		suiteName := result.AttackTechniqueId
+		if result.AttackTechniqueName != "" {
+			suiteName = fmt.Sprintf("%s: %s", result.AttackTechniqueId, result.AttackTechniqueName)
+		}
+		suite, ok := suites[suiteName]
+		if !ok {
+			suite = &JUnitTestSuite{
+				Name:      suiteName,
+				Timestamp: result.Time.Format(time.RFC3339),
+			}
+			if result.Host != nil {
+				suite.Hostname = result.Host.Hostname
+			}
+			if result.AttackTechniqueId != "" {
+				suite.Properties = append(suite.Properties, JUnitProperty{Name: "attack_technique_id", Value: result.AttackTechniqueId})
+			}
+			suites[suiteName] = suite
+			suiteNames = append(suiteNames, suiteName)
+		}
+		testCase := newJUnitTestCase(result)
+		suite.Cases = append(suite.Cases, testCase)
+		suite.Tests++
+		suite.Time += testCase.Time
+		if testCase.Failure != nil {
+			suite.Failures++
+		} else if testCase.Error != nil {
+			suite.Errors++
+		} else if testCase.Skipped != nil {
+			suite.Skipped++
+		}
+	}
+	sort.Strings(suiteNames)
+	for _, suiteName := range suiteNames {
+		suite := suites[suiteName]
+		report.Suites = append(report.Suites, *suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+		report.Time += suite.Time
+	}
+	return report
+}
+
+func newJUnitTestCase(result TestResult) JUnitTestCase {
+	testCase := JUnitTestCase{
+		Name:      fmt.Sprintf("%s (%s)", result.Test.Name, result.Test.AutoGeneratedGuid),
+		Classname: result.AttackTechniqueId,
+		Time:      result.GetDuration().Seconds(),
+	}
+	message := &JUnitMessage{Message: result.Reason, Text: result.Reason}
+	switch result.Status {
+	case TestStatusFailed:
+		message.Type = "failure"
+		if unmet := getUnmetDependencies(result); unmet != "" {
+			message.Type = "dependencies"
+			message.Text = unmet
+		}
+		testCase.Failure = message
+	case TestStatusTimedOut:
+		message.Type = "timeout"
+		testCase.Failure = message
+	case TestStatusErrored:
+		message.Type = "error"
+		testCase.Error = message
+	case TestStatusSkipped:
+		testCase.Skipped = &JUnitMessage{Message: result.Reason}
+	}
+
+	var stdout, stderr strings.Builder
+	for _, dependency := range result.Dependencies {
+		for _, executedCommand := range dependency.ExecutedCommands {
+			writeJUnitOutput(&stdout, &stderr, "dependency: "+strings.TrimSpace(dependency.Dependency.Description), executedCommand.Command.Command, executedCommand.ExitCode, executedCommand.Stdout, executedCommand.Stderr)
+		}
+	}
+	for i, executedCommand := range result.ExecutedCommands {
+		label := "test command"
+		if i > 0 {
+			label = "cleanup command"
+		}
+		writeJUnitOutput(&stdout, &stderr, label, executedCommand.Command.Command, executedCommand.ExitCode, executedCommand.Stdout, executedCommand.Stderr)
+	}
+	testCase.SystemOut = newJUnitOutput(stdout.String())
+	testCase.SystemErr = newJUnitOutput(stderr.String())
+	return testCase
+}
+
+func getUnmetDependencies(result TestResult) string {
+	var unmet []string
+	for _, dependency := range result.Dependencies {
+		if !dependency.Met {
+			unmet = append(unmet, "- "+strings.TrimSpace(dependency.Dependency.Description))
+		}
+	}
+	return strings.Join(unmet, "\n")
+}
+
+func writeJUnitOutput(stdout, stderr *strings.Builder, label, command string, exitCode int, out, err string) {
+	fmt.Fprintf(stdout, "==> %s (exit code: %d)\n$ %s\n", label, exitCode, strings.TrimSpace(command))
+	if out != "" {
+		stdout.WriteString(strings.TrimRight(out, "\n") + "\n")
+	}
+	if err != "" {
+		fmt.Fprintf(stderr, "==> %s (exit code: %d)\n%s\n", label, exitCode, strings.TrimRight(err, "\n"))
+	}
+}
+
+// WriteJUnitReport writes test results to the provided writer as a JUnit XML report.
+func WriteJUnitReport(w io.Writer, name string, results []TestResult) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(NewJUnitReport(name, results))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
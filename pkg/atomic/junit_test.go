@@ -0,0 +1,137 @@
+package atomic
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/whitfieldsdad/go-building-blocks/pkg/bb"
+)
+
+func TestNewJUnitReport(t *testing.T) {
+	type counts struct {
+		tests, failures, errors, skipped int
+	}
+	tests := []struct {
+		name     string
+		statuses []TestStatus
+		want     counts
+	}{
+		{name: "no results", want: counts{}},
+		{name: "passed", statuses: []TestStatus{TestStatusPassed, TestStatusPassed}, want: counts{tests: 2}},
+		{name: "failed and timed out tests are failures", statuses: []TestStatus{TestStatusFailed, TestStatusTimedOut}, want: counts{tests: 2, failures: 2}},
+		{name: "errored", statuses: []TestStatus{TestStatusErrored}, want: counts{tests: 1, errors: 1}},
+		{name: "skipped", statuses: []TestStatus{TestStatusSkipped, TestStatusPassed}, want: counts{tests: 2, skipped: 1}},
+		{
+			name:     "mixed",
+			statuses: []TestStatus{TestStatusPassed, TestStatusFailed, TestStatusErrored, TestStatusSkipped, TestStatusTimedOut},
+			want:     counts{tests: 5, failures: 2, errors: 1, skipped: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Alternate between two ATT&CK techniques so that results are grouped into more than one suite.
+			var results []TestResult
+			techniqueIds := make(map[string]bool)
+			for i, status := range tt.statuses {
+				techniqueId := "T1057"
+				if i%2 == 1 {
+					techniqueId = "T1003"
+				}
+				techniqueIds[techniqueId] = true
+				test := Test{Name: fmt.Sprintf("Test %d", i), AutoGeneratedGuid: fmt.Sprintf("%d", i)}
+				results = append(results, TestResult{Test: test, Status: status, Reason: string(status), AttackTechniqueId: techniqueId})
+			}
+			report := NewJUnitReport("results", results)
+
+			got := counts{report.Tests, report.Failures, report.Errors, report.Skipped}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if len(report.Suites) != len(techniqueIds) {
+				t.Fatalf("expected %d suites, got %d", len(techniqueIds), len(report.Suites))
+			}
+
+			// The totals of the report must match the totals of its suites and test cases.
+			var total counts
+			for _, suite := range report.Suites {
+				var suiteTotal counts
+				for _, testCase := range suite.Cases {
+					suiteTotal.tests++
+					if testCase.Failure != nil {
+						suiteTotal.failures++
+					}
+					if testCase.Error != nil {
+						suiteTotal.errors++
+					}
+					if testCase.Skipped != nil {
+						suiteTotal.skipped++
+					}
+				}
+				if (counts{suite.Tests, suite.Failures, suite.Errors, suite.Skipped}) != suiteTotal {
+					t.Errorf("%s: suite counts don't match its test cases: %+v", suite.Name, suite)
+				}
+				total.tests += suiteTotal.tests
+				total.failures += suiteTotal.failures
+				total.errors += suiteTotal.errors
+				total.skipped += suiteTotal.skipped
+			}
+			if total != tt.want {
+				t.Errorf("got test cases %+v, want %+v", total, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewJUnitReportUnmetDependencies(t *testing.T) {
+	result := TestResult{
+		Test:              Test{Name: "Test", AutoGeneratedGuid: "1"},
+		Status:            TestStatusFailed,
+		Reason:            "dependencies not met",
+		AttackTechniqueId: "T1057",
+		Dependencies: []DependencyResolutionResult{
+			{Dependency: Dependency{Description: "ps must exist\n"}, Met: false},
+			{Dependency: Dependency{Description: "sh must exist"}, Met: true},
+		},
+	}
+	report := NewJUnitReport("results", []TestResult{result})
+	if report.Failures != 1 {
+		t.Fatalf("expected 1 failure, got %d", report.Failures)
+	}
+	failure := report.Suites[0].Cases[0].Failure
+	if failure.Type != "dependencies" || failure.Text != "- ps must exist" {
+		t.Errorf("unexpected failure: %+v", failure)
+	}
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	result := TestResult{
+		Test:              Test{Name: "Test", AutoGeneratedGuid: "1"},
+		Status:            TestStatusPassed,
+		AttackTechniqueId: "T1057",
+		ExecutedCommands: []ExecutedCommand{
+			{ExecutedCommand: bb.ExecutedCommand{Stdout: "\x1b[1mok\x1b[0m\n"}},
+		},
+	}
+	buf := &bytes.Buffer{}
+	err := WriteJUnitReport(buf, "results", []TestResult{result})
+	if err != nil {
+		t.Fatalf("failed to write report: %s", err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("expected an XML header, got %q", buf.String())
+	}
+	var report JUnitTestSuites
+	err = xml.Unmarshal(buf.Bytes(), &report)
+	if err != nil {
+		t.Fatalf("failed to parse report: %s", err)
+	}
+	if report.Tests != 1 || len(report.Suites) != 1 || len(report.Suites[0].Cases) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if stdout := report.Suites[0].Cases[0].SystemOut; stdout == nil || !strings.Contains(stdout.Text, "[1mok[0m") {
+		t.Errorf("unexpected output: %+v", stdout)
+	}
+}
@@ -0,0 +1,119 @@
+package atomic
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// MarkdownExcerptLines is the maximum number of lines of output that are included for each failed test.
+const MarkdownExcerptLines = 20
+
+var markdownReportFuncs = template.FuncMap{
+	"cell": escapeMarkdownTableCell,
+	"link": func(id string) string {
+		if id == "" {
+			return ""
+		}
+		return fmt.Sprintf("[%s](%s)", id, GetAttackTechniqueURL(id))
+	},
+	"formatTime": func(t time.Time) string {
+		return t.UTC().Format(time.RFC3339)
+	},
+	"formatDuration": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	},
+	"trim":    strings.TrimSpace,
+	"excerpt": getExcerpt,
+	"fence":   getCodeFence,
+	"add": func(values ...int) int {
+		total := 0
+		for _, v := range values {
+			total += v
+		}
+		return total
+	},
+}
+
+var markdownReportTemplate = template.Must(template.New("report").Funcs(markdownReportFuncs).Parse(`# {{.Title}}
+
+| Tests run | Passed | Failed | Skipped | Errored | Duration |
+|----------:|-------:|-------:|--------:|--------:|---------:|
+| {{.Summary.Total}} | {{.Summary.Passed}} | {{.Summary.Failed}} | {{.Summary.Skipped}} | {{add .Summary.Errored .Summary.TimedOut}} | {{formatDuration .GetDuration}} |
+
+{{if .Hosts}}Hosts: {{range $i, $host := .Hosts}}{{if $i}}, {{end}}` + "`{{$host}}`" + `{{end}}  
+{{end}}Started: {{formatTime .StartTime}}
+
+## Techniques
+
+| Technique | Name | Status | Passed | Failed | Skipped |
+|-----------|------|--------|-------:|-------:|--------:|
+{{- range .Techniques}}
+| {{link .Id}} | {{cell .Name}} | {{.Status}} | {{.Summary.Passed}} | {{add .Summary.Failed .Summary.Errored .Summary.TimedOut}} | {{.Summary.Skipped}} |
+{{- end}}
+{{with .GetFailures}}
+## Failures
+{{range .}}
+### {{link .AttackTechniqueId}}: {{.Test.Name}}
+
+- Test: ` + "`{{.Test.AutoGeneratedGuid}}`" + `
+- Status: {{.Status}}{{if .Reason}} ({{.Reason}}){{end}}
+{{- if .Host}}
+- Host: ` + "`{{.Host.Hostname}}`" + `
+{{- end}}
+{{- range $i, $command := .ExecutedCommands}}{{if not $i}}
+{{template "command" $command}}{{end}}{{end}}
+{{- range .Dependencies}}{{if not .Met}}
+- Unmet dependency: {{trim .Dependency.Description}}
+{{- end}}{{end}}
+{{end}}{{end}}
+{{- define "command"}}
+{{- $fence := fence .Command.Command}}
+{{$fence}}{{.Command.CommandType}}
+{{trim .Command.Command}}
+{{$fence}}
+{{with excerpt .Stderr}}
+{{- $fence := fence .}}
+stderr:
+
+{{$fence}}
+{{.}}
+{{$fence}}
+{{end}}
+{{- end}}
+`))
+
+// WriteMarkdownReport writes a summary of a report in Markdown, including a section for each failed test.
+func WriteMarkdownReport(w io.Writer, report *Report) error {
+	return markdownReportTemplate.Execute(w, report)
+}
+
+func escapeMarkdownTableCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// getExcerpt returns the last lines of the provided output.
+func getExcerpt(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > MarkdownExcerptLines {
+		lines = append([]string{fmt.Sprintf("... (%d lines omitted)", len(lines)-MarkdownExcerptLines)}, lines[len(lines)-MarkdownExcerptLines:]...)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// getCodeFence returns a code fence that is longer than any run of backticks in the provided text.
+func getCodeFence(s string) string {
+	longest, current := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			current++
+			longest = max(longest, current)
+		} else {
+			current = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
@@ -2,14 +2,23 @@ package atomic
 
 import (
 	"os"
+	"time"
 )
 
 var (
 	DefaultAtomicsDir = os.ExpandEnv("$ATOMICS_DIR")
 )
 
+// TestOptions are the options used when running a test. Timeouts are expressed in seconds, and a timeout of zero means
+// that there is no timeout.
 type TestOptions struct {
-	InputArguments map[string]interface{} `json:"input_arguments" yaml:"input_arguments"`
+	InputArguments    map[string]interface{} `json:"input_arguments,omitempty" yaml:"input_arguments,omitempty"`
+	Timeout           float64                `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	DependencyTimeout float64                `json:"dependency_timeout,omitempty" yaml:"dependency_timeout,omitempty"`
+	CleanupTimeout    float64                `json:"cleanup_timeout,omitempty" yaml:"cleanup_timeout,omitempty"`
+
+	// Exclusive indicates that the test must not run concurrently with any other tests.
+	Exclusive bool `json:"exclusive,omitempty" yaml:"exclusive,omitempty"`
 }
 
 func NewTestOptions() *TestOptions {
@@ -17,3 +26,48 @@ func NewTestOptions() *TestOptions {
 		InputArguments: make(map[string]interface{}),
 	}
 }
+
+// IsZero returns true if none of the options are set.
+func (o TestOptions) IsZero() bool {
+	return len(o.InputArguments) == 0 && o.Timeout == 0 && o.DependencyTimeout == 0 && o.CleanupTimeout == 0 && !o.Exclusive
+}
+
+// GetTimeout returns the maximum amount of time that the test command may run for.
+func (o TestOptions) GetTimeout() time.Duration {
+	return secondsToDuration(o.Timeout)
+}
+
+// GetDependencyTimeout returns the maximum amount of time that dependency resolution may take.
+func (o TestOptions) GetDependencyTimeout() time.Duration {
+	return secondsToDuration(o.DependencyTimeout)
+}
+
+// GetCleanupTimeout returns the maximum amount of time that the cleanup command may run for.
+func (o TestOptions) GetCleanupTimeout() time.Duration {
+	return secondsToDuration(o.CleanupTimeout)
+}
+
+// MergeTestOptions combines test options, with the values from later options taking precedence.
+func MergeTestOptions(opts ...TestOptions) *TestOptions {
+	combined := NewTestOptions()
+	for _, o := range opts {
+		for k, v := range o.InputArguments {
+			combined.InputArguments[k] = v
+		}
+		if o.Timeout > 0 {
+			combined.Timeout = o.Timeout
+		}
+		if o.DependencyTimeout > 0 {
+			combined.DependencyTimeout = o.DependencyTimeout
+		}
+		if o.CleanupTimeout > 0 {
+			combined.CleanupTimeout = o.CleanupTimeout
+		}
+		combined.Exclusive = combined.Exclusive || o.Exclusive
+	}
+	return combined
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,25 @@
+//go:build !unix && !windows
+
+package atomic
+
+import "os/exec"
+
+// processGroup is a placeholder on platforms without process groups. Only the command's own process is killed.
+type processGroup struct{}
+
+func newProcessGroup(cmd *exec.Cmd) (*processGroup, error) {
+	return &processGroup{}, nil
+}
+
+// start starts the command in the group.
+func (g *processGroup) start(cmd *exec.Cmd) error {
+	return cmd.Start()
+}
+
+func (g *processGroup) kill(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func (g *processGroup) close() {}
+
+func setCommandLine(cmd *exec.Cmd, executorName, command string) {}
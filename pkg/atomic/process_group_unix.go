@@ -0,0 +1,30 @@
+//go:build unix
+
+package atomic
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// processGroup is the process group that a command is started in. The group's ID is the PID of the command.
+type processGroup struct{}
+
+func newProcessGroup(cmd *exec.Cmd) (*processGroup, error) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return &processGroup{}, nil
+}
+
+// start starts the command in the group.
+func (g *processGroup) start(cmd *exec.Cmd) error {
+	return cmd.Start()
+}
+
+// kill kills every process in the group, including descendants of the command that are still running after it exited.
+func (g *processGroup) kill(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+func (g *processGroup) close() {}
+
+func setCommandLine(cmd *exec.Cmd, executorName, command string) {}
@@ -0,0 +1,127 @@
+package atomic
+
+import (
+	"os/exec"
+	"syscall"
+	"unsafe"
+
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+// processGroup is the Job Object that a command is assigned to. Processes that are started by the command are assigned
+// to the same job.
+type processGroup struct {
+	job windows.Handle
+}
+
+func newProcessGroup(cmd *exec.Cmd) (*processGroup, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Kill any remaining processes if the job is closed without being terminated (e.g. if this process crashes).
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{
+		BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{
+			LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE,
+		},
+	}
+	_, err = windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+	if err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	return &processGroup{job: job}, nil
+}
+
+// start starts the command suspended, assigns it to the job, and then resumes it, so that every process that the
+// command starts is part of the job.
+func (g *processGroup) start(cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+	pid := uint32(cmd.Process.Pid)
+	err = g.assign(pid)
+	if err != nil {
+		log.Warnf("Failed to assign process %d to a Job Object - processes that it starts won't be killed if it times out: %s", pid, err)
+	}
+	err = resumeProcess(pid)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return errors.Wrapf(err, "failed to resume process %d", pid)
+	}
+	return nil
+}
+
+func (g *processGroup) assign(pid uint32) error {
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, pid)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(process)
+	return windows.AssignProcessToJobObject(g.job, process)
+}
+
+// resumeProcess resumes the threads of a process that was started suspended.
+func resumeProcess(pid uint32) error {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(snapshot)
+
+	entry := windows.ThreadEntry32{}
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	resumed := false
+	for err = windows.Thread32First(snapshot, &entry); err == nil; err = windows.Thread32Next(snapshot, &entry) {
+		if entry.OwnerProcessID != pid {
+			continue
+		}
+		thread, err := windows.OpenThread(windows.THREAD_SUSPEND_RESUME, false, entry.ThreadID)
+		if err != nil {
+			return err
+		}
+		_, err = windows.ResumeThread(thread)
+		windows.CloseHandle(thread)
+		if err != nil {
+			return err
+		}
+		resumed = true
+	}
+	if !resumed {
+		return errors.New("no threads found")
+	}
+	return nil
+}
+
+// kill terminates every process in the job.
+func (g *processGroup) kill(cmd *exec.Cmd) error {
+	err := windows.TerminateJobObject(g.job, 1)
+	if err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
+
+func (g *processGroup) close() {
+	windows.CloseHandle(g.job)
+}
+
+// setCommandLine passes commands to cmd.exe verbatim, since cmd.exe doesn't follow the quoting rules that are used to
+// build command lines from arguments.
+func setCommandLine(cmd *exec.Cmd, executorName, command string) {
+	if executorName != "command_prompt" {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CmdLine = syscall.EscapeArg(cmd.Path) + " /c " + command
+}
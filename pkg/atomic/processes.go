@@ -0,0 +1,266 @@
+package atomic
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/elastic/go-sysinfo"
+	"github.com/elastic/go-sysinfo/types"
+	"github.com/whitfieldsdad/go-building-blocks/pkg/bb"
+)
+
+const (
+	// processWaitDelay is how long to wait for the output of a command to be closed after it exits (e.g. by processes
+	// that it started in the background) before giving up on reading it.
+	processWaitDelay = 5 * time.Second
+
+	// processPollInterval is how often the process tree of a running command is inspected.
+	processPollInterval = 100 * time.Millisecond
+)
+
+// ExecutedCommand is a command that was executed, along with the processes that were observed in its process tree.
+type ExecutedCommand struct {
+	bb.ExecutedCommand `yaml:",inline"`
+	Processes          []bb.Process `json:"processes,omitempty" yaml:"processes,omitempty"`
+}
+
+// GetProcesses returns the processes that were observed in the command's process tree, starting with the process that
+// was started to execute the command.
+func (c ExecutedCommand) GetProcesses() []bb.Process {
+	if len(c.Processes) > 0 {
+		return c.Processes
+	}
+	return c.ExecutedCommand.GetProcesses()
+}
+
+// executeCommand executes a command. Commands are started in their own process group (or Job Object, on Windows) so
+// that if the context is cancelled or its deadline is exceeded while the command is running, the command's entire
+// process tree is killed, rather than only the process that was started directly.
+//
+// The processes in the command's process tree are recorded while the command is running (see processTreeCollector).
+func executeCommand(ctx context.Context, command, executorName string) (*ExecutedCommand, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	path, err := GetExecutorPath(executorName)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, getExecutorArgs(executorName, command)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = processWaitDelay
+	setCommandLine(cmd, executorName, command)
+
+	group, err := newProcessGroup(cmd)
+	if err != nil {
+		return nil, err
+	}
+	defer group.close()
+	cmd.Cancel = func() error {
+		log.Warnf("Killing process tree (reason: %s)", context.Cause(ctx))
+		return group.kill(cmd)
+	}
+
+	executedCommand := &ExecutedCommand{
+		ExecutedCommand: bb.ExecutedCommand{
+			Command:   bb.Command{Command: command, CommandType: executorName},
+			StartTime: time.Now(),
+		},
+	}
+	err = group.start(cmd)
+	if err != nil {
+		return nil, err
+	}
+	pid := cmd.Process.Pid
+	collector := newProcessTreeCollector(pid, cmd.Path)
+	stop := collector.start()
+	emitter := getEventEmitter(ctx)
+	objectId := emitter.processStarted(command, collector.root())
+
+	err = cmd.Wait()
+	executedCommand.Processes = stop()
+	executedCommand.EndTime = time.Now()
+	executedCommand.ExitCode = cmd.ProcessState.ExitCode()
+	executedCommand.Stdout = stdout.String()
+	executedCommand.Stderr = stderr.String()
+	emitter.processExited(objectId, pid, executedCommand)
+
+	if ctx.Err() != nil {
+		return executedCommand, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !errors.Is(err, exec.ErrWaitDelay) {
+		return executedCommand, err
+	}
+	return executedCommand, nil
+}
+
+// withTimeout returns a context with the provided timeout, or without a timeout if the timeout is zero.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func isTimeout(ctx context.Context) bool {
+	return ctx.Err() == context.DeadlineExceeded
+}
+
+// processTreeCollector records the processes in the process tree of a command while it is running. The process tree is
+// inspected periodically, so processes that start and exit between two inspections aren't observed.
+type processTreeCollector struct {
+	pid        int
+	executable string
+
+	mu        sync.Mutex
+	pids      map[int]bool
+	processes []types.ProcessInfo
+}
+
+func newProcessTreeCollector(pid int, executable string) *processTreeCollector {
+	return &processTreeCollector{
+		pid:        pid,
+		executable: executable,
+		pids:       map[int]bool{pid: true},
+		processes:  []types.ProcessInfo{{PID: pid, PPID: os.Getpid(), Exe: executable}},
+	}
+}
+
+// root returns the process that was started to execute the command.
+func (c *processTreeCollector) root() bb.Process {
+	return newProcess(c.processes[0], map[string]*bb.Hashes{})
+}
+
+// start inspects the process tree until the returned function is called. The returned function returns the processes
+// that were observed.
+func (c *processTreeCollector) start() func() []bb.Process {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(processPollInterval)
+		defer ticker.Stop()
+		for {
+			err := c.inspect()
+			if err != nil {
+				log.Debugf("Failed to inspect the process tree of process %d: %s", c.pid, err)
+				return
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() []bb.Process {
+		close(done)
+		<-stopped
+		return c.getProcesses()
+	}
+}
+
+// inspect records any processes whose parents are in the process tree.
+func (c *processTreeCollector) inspect() error {
+	processes, err := listProcesses()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for added := true; added; {
+		added = false
+		for _, process := range processes {
+			if c.pids[process.PPID] && !c.pids[process.PID] {
+				c.pids[process.PID] = true
+				c.processes = append(c.processes, process)
+				added = true
+			}
+		}
+	}
+	// The process that was started to execute the command might have exec'd another executable.
+	for _, process := range processes {
+		if process.PID == c.pid && process.Exe != "" {
+			c.processes[0].Exe = process.Exe
+			c.processes[0].Args = process.Args
+			c.processes[0].StartTime = process.StartTime
+		}
+	}
+	return nil
+}
+
+func (c *processTreeCollector) getProcesses() []bb.Process {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hashes := make(map[string]*bb.Hashes)
+	processes := make([]bb.Process, 0, len(c.processes))
+	for _, process := range c.processes {
+		processes = append(processes, newProcess(process, hashes))
+	}
+	return processes
+}
+
+// newProcess converts process information into a process. The hashes of executables are cached by path.
+func newProcess(info types.ProcessInfo, hashes map[string]*bb.Hashes) bb.Process {
+	process := bb.Process{PID: info.PID, PPID: info.PPID}
+	if info.Exe == "" {
+		return process
+	}
+	if _, ok := hashes[info.Exe]; !ok {
+		h, err := hashFile(info.Exe)
+		if err != nil {
+			log.Debugf("Failed to hash %s: %s", info.Exe, err)
+		}
+		hashes[info.Exe] = h
+	}
+	process.Executable = &bb.File{Path: info.Exe, Hashes: hashes[info.Exe]}
+	return process
+}
+
+func hashFile(path string) (*bb.Hashes, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	md5Hash, sha1Hash, sha256Hash := md5.New(), sha1.New(), sha256.New()
+	_, err = io.Copy(io.MultiWriter(md5Hash, sha1Hash, sha256Hash), f)
+	if err != nil {
+		return nil, err
+	}
+	return &bb.Hashes{
+		MD5:    hex.EncodeToString(md5Hash.Sum(nil)),
+		SHA1:   hex.EncodeToString(sha1Hash.Sum(nil)),
+		SHA256: hex.EncodeToString(sha256Hash.Sum(nil)),
+	}, nil
+}
+
+func listProcesses() ([]types.ProcessInfo, error) {
+	processes, err := sysinfo.Processes()
+	if err != nil {
+		return nil, err
+	}
+	var infos []types.ProcessInfo
+	for _, process := range processes {
+		info, err := process.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
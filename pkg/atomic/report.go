@@ -0,0 +1,265 @@
+package atomic
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/whitfieldsdad/go-building-blocks/pkg/bb"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	UnknownTactic = "unknown"
+
+	// ReportStatusMixed is the status of a technique with tests that both passed and failed.
+	ReportStatusMixed TestStatus = "mixed"
+)
+
+// Report summarizes a set of test results by ATT&CK tactic and technique. It is used to render HTML and Markdown
+// reports.
+type Report struct {
+	Title       string            `json:"title" yaml:"title"`
+	GeneratedAt time.Time         `json:"generated_at" yaml:"generated_at"`
+	StartTime   time.Time         `json:"start_time" yaml:"start_time"`
+	EndTime     time.Time         `json:"end_time" yaml:"end_time"`
+	Hosts       []string          `json:"hosts" yaml:"hosts"`
+	Summary     TestResultSummary `json:"summary" yaml:"summary"`
+	Tactics     []ReportTactic    `json:"tactics" yaml:"tactics"`
+	Techniques  []ReportTechnique `json:"techniques" yaml:"techniques"`
+	Results     []TestResult      `json:"results" yaml:"results"`
+}
+
+// GetDuration returns the amount of time between the first test being started and the last test completing.
+func (r Report) GetDuration() time.Duration {
+	return r.EndTime.Sub(r.StartTime)
+}
+
+// GetFailures returns the results of tests that failed, errored, or timed out.
+func (r Report) GetFailures() []TestResult {
+	var failures []TestResult
+	for _, result := range r.Results {
+		if result.Status.IsFailure() {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+type ReportTactic struct {
+	Name       string            `json:"name" yaml:"name"`
+	Summary    TestResultSummary `json:"summary" yaml:"summary"`
+	Techniques []ReportTechnique `json:"techniques" yaml:"techniques"`
+}
+
+type ReportTechnique struct {
+	Id      string            `json:"id" yaml:"id"`
+	Name    string            `json:"name" yaml:"name"`
+	Status  TestStatus        `json:"status" yaml:"status"`
+	Summary TestResultSummary `json:"summary" yaml:"summary"`
+}
+
+// GetURL returns the URL of the technique's page on the ATT&CK website.
+func (t ReportTechnique) GetURL() string {
+	return GetAttackTechniqueURL(t.Id)
+}
+
+// GetAttackTechniqueURL returns the URL of a technique's page on the ATT&CK website (e.g.
+// https://attack.mitre.org/techniques/T1003/001/ for T1003.001).
+func GetAttackTechniqueURL(id string) string {
+	if id == "" {
+		return ""
+	}
+	return "https://attack.mitre.org/techniques/" + strings.ReplaceAll(strings.ToUpper(id), ".", "/") + "/"
+}
+
+// NewReport summarizes a set of test results. Tactics maps ATT&CK technique IDs to the tactics that they belong to
+// (see ReadAttackTactics); techniques without any tactics are reported under an "unknown" tactic.
+func NewReport(title string, results []TestResult, tactics map[string][]string) *Report {
+	report := &Report{
+		Title:       title,
+		GeneratedAt: time.Now(),
+		Summary:     SummarizeTestResults(results),
+		Results:     slices.Clone(results),
+	}
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		a, b := report.Results[i], report.Results[j]
+		if a.AttackTechniqueId != b.AttackTechniqueId {
+			return a.AttackTechniqueId < b.AttackTechniqueId
+		}
+		return a.Test.Name < b.Test.Name
+	})
+
+	resultsByTechnique := make(map[string][]TestResult)
+	var techniqueIds []string
+	for _, result := range report.Results {
+		if report.StartTime.IsZero() || result.Time.Before(report.StartTime) {
+			report.StartTime = result.Time
+		}
+		if end := result.Time.Add(result.GetDuration()); end.After(report.EndTime) {
+			report.EndTime = end
+		}
+		host := result.HostId
+		if result.Host != nil {
+			host = result.Host.Hostname
+		}
+		if host != "" && !slices.Contains(report.Hosts, host) {
+			report.Hosts = append(report.Hosts, host)
+		}
+		if _, ok := resultsByTechnique[result.AttackTechniqueId]; !ok {
+			techniqueIds = append(techniqueIds, result.AttackTechniqueId)
+		}
+		resultsByTechnique[result.AttackTechniqueId] = append(resultsByTechnique[result.AttackTechniqueId], result)
+	}
+
+	resultsByTactic := make(map[string][]TestResult)
+	techniquesByTactic := make(map[string][]ReportTechnique)
+	for _, id := range techniqueIds {
+		techniqueResults := resultsByTechnique[id]
+		technique := ReportTechnique{
+			Id:      id,
+			Name:    techniqueResults[0].AttackTechniqueName,
+			Status:  getReportStatus(techniqueResults),
+			Summary: SummarizeTestResults(techniqueResults),
+		}
+		report.Techniques = append(report.Techniques, technique)
+
+		techniqueTactics := getTactics(tactics, id)
+		for _, tactic := range techniqueTactics {
+			resultsByTactic[tactic] = append(resultsByTactic[tactic], techniqueResults...)
+			techniquesByTactic[tactic] = append(techniquesByTactic[tactic], technique)
+		}
+	}
+	for _, tactic := range sortTactics(techniquesByTactic) {
+		report.Tactics = append(report.Tactics, ReportTactic{
+			Name:       tactic,
+			Summary:    SummarizeTestResults(resultsByTactic[tactic]),
+			Techniques: techniquesByTactic[tactic],
+		})
+	}
+	return report
+}
+
+// getTactics returns the tactics of a technique. Sub-techniques that aren't listed inherit the tactics of their parent
+// technique.
+func getTactics(tactics map[string][]string, techniqueId string) []string {
+	if t := tactics[techniqueId]; len(t) > 0 {
+		return t
+	}
+	if parent, _, ok := strings.Cut(techniqueId, "."); ok {
+		if t := tactics[parent]; len(t) > 0 {
+			return t
+		}
+	}
+	return []string{UnknownTactic}
+}
+
+// attackTacticOrder is the order in which tactics appear in the enterprise ATT&CK matrix.
+var attackTacticOrder = []string{
+	"reconnaissance",
+	"resource-development",
+	"initial-access",
+	"execution",
+	"persistence",
+	"privilege-escalation",
+	"defense-evasion",
+	"credential-access",
+	"discovery",
+	"lateral-movement",
+	"collection",
+	"command-and-control",
+	"exfiltration",
+	"impact",
+}
+
+func sortTactics(techniquesByTactic map[string][]ReportTechnique) []string {
+	var tactics []string
+	for tactic := range techniquesByTactic {
+		tactics = append(tactics, tactic)
+	}
+	rank := func(tactic string) int {
+		if i := slices.Index(attackTacticOrder, tactic); i >= 0 {
+			return i
+		}
+		return len(attackTacticOrder)
+	}
+	sort.Slice(tactics, func(i, j int) bool {
+		if rank(tactics[i]) != rank(tactics[j]) {
+			return rank(tactics[i]) < rank(tactics[j])
+		}
+		return tactics[i] < tactics[j]
+	})
+	return tactics
+}
+
+// getReportStatus returns the status shared by all of the provided results, or "mixed" if tests both passed and failed.
+// Skipped tests are ignored unless every test was skipped.
+func getReportStatus(results []TestResult) TestStatus {
+	var statuses []TestStatus
+	for _, result := range results {
+		status := result.Status
+		if status.IsFailure() {
+			status = TestStatusFailed
+		}
+		if status != TestStatusSkipped && !slices.Contains(statuses, status) {
+			statuses = append(statuses, status)
+		}
+	}
+	switch len(statuses) {
+	case 0:
+		return TestStatusSkipped
+	case 1:
+		return statuses[0]
+	}
+	return ReportStatusMixed
+}
+
+// ReadAttackTactics maps ATT&CK technique IDs to tactics using the index that is distributed with Atomic Red Team
+// (i.e. atomics/Indexes/index.yaml), which is keyed by tactic and then by technique.
+func ReadAttackTactics(atomicsDir string) (map[string][]string, error) {
+	blob, err := os.ReadFile(filepath.Join(atomicsDir, "Indexes", "index.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var index map[string]map[string]struct{}
+	err = yaml.Unmarshal(blob, &index)
+	if err != nil {
+		return nil, err
+	}
+	tactics := make(map[string][]string)
+	for tactic, techniques := range index {
+		for techniqueId := range techniques {
+			tactics[techniqueId] = append(tactics[techniqueId], tactic)
+		}
+	}
+	return tactics, nil
+}
+
+// ReportProcess is a node in the tree of processes that were spawned by a command.
+type ReportProcess struct {
+	bb.Process
+	Children []*ReportProcess
+}
+
+// GetProcessTree arranges the processes that were spawned by a command into trees. Processes whose parents weren't
+// observed are roots.
+func GetProcessTree(executedCommand ExecutedCommand) []*ReportProcess {
+	processes := executedCommand.GetProcesses()
+	nodes := make(map[int]*ReportProcess)
+	for _, process := range processes {
+		nodes[process.PID] = &ReportProcess{Process: process}
+	}
+	var roots []*ReportProcess
+	for _, process := range processes {
+		node := nodes[process.PID]
+		if parent, ok := nodes[process.PPID]; ok && parent != node {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
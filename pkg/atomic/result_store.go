@@ -0,0 +1,460 @@
+package atomic
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	EnvResultStoreDir = "GO_ATOMIC_RESULT_STORE_DIR"
+)
+
+// GetResultStoreDir returns the directory that results are stored in. It can be set using the
+// GO_ATOMIC_RESULT_STORE_DIR environment variable.
+func GetResultStoreDir() string {
+	dir := os.Getenv(EnvResultStoreDir)
+	if dir != "" {
+		return dir
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir = os.TempDir()
+	}
+	return filepath.Join(configDir, appId, "results")
+}
+
+// ResultStore is a local store of test results and events.
+//
+// Each result is stored as a JSON file in the "results" subdirectory, and the events of each test invocation are
+// stored as JSON lines in the "events" subdirectory. Queries read every result, which is fast enough for the number of
+// results that a single host or lab produces.
+type ResultStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+func NewResultStore(dir string) *ResultStore {
+	return &ResultStore{Dir: dir}
+}
+
+func (s *ResultStore) resultsDir() string {
+	return filepath.Join(s.Dir, "results")
+}
+
+func (s *ResultStore) eventsDir() string {
+	return filepath.Join(s.Dir, "events")
+}
+
+// SaveResult adds a result to the store, replacing any result with the same ID.
+func (s *ResultStore) SaveResult(result TestResult) error {
+	return writeJSONFile(s.resultsDir(), result.Id, result)
+}
+
+// idPattern matches IDs that can safely be used as file names (e.g. UUIDs). IDs may not contain path separators or
+// glob patterns, or start with a dot.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// validateId returns an error if an ID, which may have been provided by a user, can't be used as a file name.
+func validateId(id string) error {
+	if !idPattern.MatchString(id) {
+		return errors.Errorf("invalid ID: %q", id)
+	}
+	return nil
+}
+
+// GetResult returns the result with the provided ID, or an ID prefix that matches exactly one result.
+func (s *ResultStore) GetResult(id string) (*TestResult, error) {
+	err := validateId(id)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.readResult(filepath.Join(s.resultsDir(), id+".json"))
+	if err == nil {
+		return result, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(s.resultsDir(), id+"*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, errors.Errorf("test result not found: %s", id)
+	} else if len(paths) > 1 {
+		return nil, errors.Errorf("ambiguous test result ID: %s", id)
+	}
+	return s.readResult(paths[0])
+}
+
+func (s *ResultStore) readResult(path string) (*TestResult, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result := &TestResult{}
+	err = json.Unmarshal(blob, result)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	result.restoreAttackTechnique()
+	return result, nil
+}
+
+// ReadTestResults reads test results from a file containing either a JSON array of results or JSON lines (e.g. the
+// output of "results export").
+func ReadTestResults(path string) ([]TestResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var results []TestResult
+	decoder := json.NewDecoder(f)
+	for {
+		var v json.RawMessage
+		err := decoder.Decode(&v)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", path)
+		}
+		if strings.HasPrefix(strings.TrimSpace(string(v)), "[") {
+			var batch []TestResult
+			err = json.Unmarshal(v, &batch)
+			results = append(results, batch...)
+		} else {
+			var result TestResult
+			err = json.Unmarshal(v, &result)
+			results = append(results, result)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", path)
+		}
+	}
+	for i := range results {
+		results[i].restoreAttackTechnique()
+	}
+	return results, nil
+}
+
+// restoreAttackTechnique copies the ATT&CK technique back into the test, since it isn't serialized as part of the test.
+func (result *TestResult) restoreAttackTechnique() {
+	result.Test.AttackTechniqueId = result.AttackTechniqueId
+	result.Test.AttackTechniqueName = result.AttackTechniqueName
+}
+
+// Emit stores an event alongside the other events of its test invocation. Events that aren't part of a test
+// invocation are ignored.
+func (s *ResultStore) Emit(event Event) error {
+	invocationId := getTestInvocationId(event)
+	if invocationId == "" {
+		return nil
+	}
+	err := validateId(invocationId)
+	if err != nil {
+		return err
+	}
+	blob, err := json.Marshal(eventEnvelope{EventType: event.GetEventType(), Event: event})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = os.MkdirAll(s.eventsDir(), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.eventsDir(), invocationId+".jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(blob, '\n'))
+	return err
+}
+
+func getTestInvocationId(event Event) string {
+	switch event := event.(type) {
+	case TestInvocation:
+		return event.Id
+	case TestStatusEvent:
+		return event.TestInvocationId
+	case ProcessStatusEvent:
+		return event.TestInvocationId
+	case TestResultEvent:
+		return event.TestInvocationId
+	}
+	return ""
+}
+
+// GetEvents returns the stored events of a test invocation, as JSON.
+func (s *ResultStore) GetEvents(invocationId string) ([]json.RawMessage, error) {
+	err := validateId(invocationId)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := os.ReadFile(filepath.Join(s.eventsDir(), invocationId+".jsonl"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var events []json.RawMessage
+	for _, line := range strings.Split(string(blob), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			events = append(events, json.RawMessage(line))
+		}
+	}
+	return events, nil
+}
+
+// ResultQuery selects results from a store. Empty fields match any result, and lists match any of their values. Test
+// IDs and ATT&CK technique IDs may be patterns (e.g. "T1003.*"). Hosts are matched by host ID or hostname.
+type ResultQuery struct {
+	TestIds            []string     `json:"test_ids,omitempty" yaml:"test_ids,omitempty"`
+	AttackTechniqueIds []string     `json:"attack_technique_ids,omitempty" yaml:"attack_technique_ids,omitempty"`
+	Hosts              []string     `json:"hosts,omitempty" yaml:"hosts,omitempty"`
+	RunIds             []string     `json:"run_ids,omitempty" yaml:"run_ids,omitempty"`
+	Statuses           []TestStatus `json:"statuses,omitempty" yaml:"statuses,omitempty"`
+	Since              *time.Time   `json:"since,omitempty" yaml:"since,omitempty"`
+	Until              *time.Time   `json:"until,omitempty" yaml:"until,omitempty"`
+
+	// Latest only selects the most recent matching result for each test on each host.
+	Latest bool `json:"latest,omitempty" yaml:"latest,omitempty"`
+
+	// Limit is the maximum number of results to return. Zero means no limit.
+	Limit int `json:"limit,omitempty" yaml:"limit,omitempty"`
+}
+
+func (q ResultQuery) Matches(result TestResult) bool {
+	if len(q.TestIds) > 0 && !matchesAnyPattern(result.Test.AutoGeneratedGuid, q.TestIds) {
+		return false
+	}
+	if len(q.RunIds) > 0 && !slices.Contains(q.RunIds, result.RunId) {
+		return false
+	}
+	if len(q.AttackTechniqueIds) > 0 && !matchesAnyPattern(result.AttackTechniqueId, q.AttackTechniqueIds) {
+		return false
+	}
+	if len(q.Hosts) > 0 {
+		hostname := ""
+		if result.Host != nil {
+			hostname = result.Host.Hostname
+		}
+		if !slices.Contains(q.Hosts, result.HostId) && !slices.ContainsFunc(q.Hosts, func(host string) bool {
+			return hostname != "" && strings.EqualFold(host, hostname)
+		}) {
+			return false
+		}
+	}
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, result.Status) {
+		return false
+	}
+	if q.Since != nil && result.Time.Before(*q.Since) {
+		return false
+	}
+	if q.Until != nil && result.Time.After(*q.Until) {
+		return false
+	}
+	return true
+}
+
+func matchesAnyPattern(s string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.EqualFold(s, pattern) {
+			return true
+		}
+		matched, _ := filepath.Match(strings.ToLower(pattern), strings.ToLower(s))
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Query returns the results that match the query, most recent first.
+func (s *ResultStore) Query(query ResultQuery) ([]TestResult, error) {
+	var results []TestResult
+	err := s.walkResults(func(path string, result TestResult) error {
+		if query.Matches(result) {
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Time.After(results[j].Time)
+	})
+	if query.Latest {
+		seen := make(map[[2]string]bool)
+		results = slices.DeleteFunc(results, func(result TestResult) bool {
+			key := [2]string{result.Test.AutoGeneratedGuid, result.HostId}
+			if seen[key] {
+				return true
+			}
+			seen[key] = true
+			return false
+		})
+	}
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results, nil
+}
+
+// Prune deletes the results (and their events) that match the query, and returns the number of results that were
+// deleted.
+func (s *ResultStore) Prune(query ResultQuery) (int, error) {
+	pruned := 0
+	err := s.walkResults(func(path string, result TestResult) error {
+		if !query.Matches(result) {
+			return nil
+		}
+		err := os.Remove(path)
+		if err != nil {
+			return err
+		}
+		if result.TestInvocationId != "" {
+			err = os.Remove(filepath.Join(s.eventsDir(), result.TestInvocationId+".jsonl"))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		pruned++
+		return nil
+	})
+	return pruned, err
+}
+
+// Export writes the results that match the query to the provided writer as JSON lines.
+func (s *ResultStore) Export(w io.Writer, query ResultQuery) (int, error) {
+	results, err := s.Query(query)
+	if err != nil {
+		return 0, err
+	}
+	encoder := json.NewEncoder(w)
+	for i, result := range results {
+		err := encoder.Encode(result)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(results), nil
+}
+
+// walkResults calls the provided function for each stored result. Results that can't be read are logged and skipped.
+func (s *ResultStore) walkResults(fn func(path string, result TestResult) error) error {
+	entries, err := os.ReadDir(s.resultsDir())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		path := filepath.Join(s.resultsDir(), name)
+		result, err := s.readResult(path)
+		if err != nil {
+			log.Warnf("Skipping unreadable test result: %s", err)
+			continue
+		}
+		err = fn(path, *result)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunSummary summarizes the results of a run.
+type RunSummary struct {
+	RunId     string            `json:"run_id" yaml:"run_id"`
+	StartTime time.Time         `json:"start_time" yaml:"start_time"`
+	Hosts     []string          `json:"hosts" yaml:"hosts"`
+	Summary   TestResultSummary `json:"summary" yaml:"summary"`
+}
+
+// ListRuns summarizes the runs that have results matching the query, most recent first. Results that weren't part of
+// a run are ignored.
+func (s *ResultStore) ListRuns(query ResultQuery) ([]RunSummary, error) {
+	query.Latest, query.Limit = false, 0
+	results, err := s.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	var runIds []string
+	resultsByRun := make(map[string][]TestResult)
+	for _, result := range results {
+		if result.RunId == "" {
+			continue
+		}
+		if _, ok := resultsByRun[result.RunId]; !ok {
+			runIds = append(runIds, result.RunId)
+		}
+		resultsByRun[result.RunId] = append(resultsByRun[result.RunId], result)
+	}
+	var runs []RunSummary
+	for _, runId := range runIds {
+		run := RunSummary{
+			RunId:   runId,
+			Summary: SummarizeTestResults(resultsByRun[runId]),
+		}
+		for _, result := range resultsByRun[runId] {
+			if run.StartTime.IsZero() || result.Time.Before(run.StartTime) {
+				run.StartTime = result.Time
+			}
+			host := result.HostId
+			if result.Host != nil {
+				host = result.Host.Hostname
+			}
+			if !slices.Contains(run.Hosts, host) {
+				run.Hosts = append(run.Hosts, host)
+			}
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// GetRun returns the results of the run with the provided ID, or an ID prefix that matches exactly one run.
+func (s *ResultStore) GetRun(runId string) ([]TestResult, error) {
+	runs, err := s.ListRuns(ResultQuery{})
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, run := range runs {
+		if run.RunId == runId {
+			matches = []string{run.RunId}
+			break
+		}
+		if strings.HasPrefix(run.RunId, runId) {
+			matches = append(matches, run.RunId)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, errors.Errorf("run not found: %s", runId)
+	} else if len(matches) > 1 {
+		return nil, errors.Errorf("ambiguous run ID: %s", runId)
+	}
+	return s.Query(ResultQuery{RunIds: matches})
+}
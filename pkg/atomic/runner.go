@@ -0,0 +1,290 @@
+package atomic
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
+	"github.com/whitfieldsdad/go-building-blocks/pkg/bb"
+)
+
+// FailurePolicy determines whether a run continues after tests fail, error, or time out.
+type FailurePolicy struct {
+	// MaxFailures is the number of failures after which no more tests are started. Zero means that every test is run.
+	MaxFailures int `json:"max_failures" yaml:"max_failures"`
+}
+
+// ParseFailurePolicy parses a failure policy from a string: "continue" runs every test, "stop" stops after the first
+// failure, and "stop-after-N" stops after N failures.
+func ParseFailurePolicy(s string) (*FailurePolicy, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "continue":
+		return &FailurePolicy{}, nil
+	case "stop":
+		return &FailurePolicy{MaxFailures: 1}, nil
+	}
+	if n, ok := strings.CutPrefix(s, "stop-after-"); ok {
+		maxFailures, err := strconv.Atoi(n)
+		if err == nil && maxFailures > 0 {
+			return &FailurePolicy{MaxFailures: maxFailures}, nil
+		}
+	}
+	return nil, errors.Errorf("invalid failure policy: %s (expected continue, stop, or stop-after-N)", s)
+}
+
+func (p FailurePolicy) String() string {
+	switch p.MaxFailures {
+	case 0:
+		return "continue"
+	case 1:
+		return "stop"
+	}
+	return fmt.Sprintf("stop-after-%d", p.MaxFailures)
+}
+
+// Runner runs tests concurrently using a bounded pool of workers.
+//
+// Tests that must run alone (see IsExclusive) wait for all running tests to complete, and no other tests are started
+// until they've completed. Once the failure policy has been triggered, tests which haven't been started are skipped.
+type Runner struct {
+	AtomicsDir    string
+	Workers       int
+	FailurePolicy FailurePolicy
+
+	// Options are combined with the options of each test, and take precedence over them.
+	Options TestOptions
+
+	// IsExclusive determines whether a test must run alone. Defaults to IsExclusiveTest.
+	IsExclusive func(PlannedTest) bool
+
+	// Events receives an event whenever a test is invoked, changes status, starts or exits a process, or completes.
+	Events EventSink
+
+	// Identity identifies the agent, host, and user in emitted events.
+	Identity Identity
+
+	// Store, if set, receives the result and events of every test.
+	Store *ResultStore
+}
+
+// RunnerResult is the outcome of running a test. A result is always provided, and an error is also provided if the test
+// was skipped or couldn't be run.
+type RunnerResult struct {
+	Test   PlannedTest `json:"test" yaml:"test"`
+	Result *TestResult `json:"result,omitempty" yaml:"result,omitempty"`
+	Err    error       `json:"-" yaml:"-"`
+}
+
+func NewRunner(atomicsDir string, workers int) *Runner {
+	return &Runner{
+		AtomicsDir:  atomicsDir,
+		Workers:     workers,
+		Options:     *NewTestOptions(),
+		IsExclusive: IsExclusiveTest,
+		Identity:    GetIdentity(),
+	}
+}
+
+// IsExclusiveTest returns true if a test requires elevation or has been marked as exclusive by its test plan. Tests
+// that change the system in ways that would interfere with other tests should be marked as exclusive.
+func IsExclusiveTest(test PlannedTest) bool {
+	return test.Options.Exclusive || test.Test.RequiresElevation()
+}
+
+// Run runs the provided tests and streams the result of each test as soon as it completes. The returned channel is
+// closed once there is a result for every test. If the context is cancelled, running tests are interrupted (and
+// cleaned up), and tests which haven't been started are skipped.
+func (r *Runner) Run(ctx context.Context, tests []PlannedTest) <-chan RunnerResult {
+	workers := max(r.Workers, 1)
+	isExclusive := r.IsExclusive
+	if isExclusive == nil {
+		isExclusive = IsExclusiveTest
+	}
+	runId := bb.NewUUID4()
+
+	queue := make(chan PlannedTest)
+	results := make(chan RunnerResult)
+	go func() {
+		defer close(queue)
+		for _, test := range tests {
+			queue <- test
+		}
+	}()
+
+	// Keep track of failures so that the failure policy can be applied.
+	var mu sync.Mutex
+	failures := 0
+	stopped := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return r.FailurePolicy.MaxFailures > 0 && failures >= r.FailurePolicy.MaxFailures
+	}
+	recordResult := func(result RunnerResult) {
+		if !result.Result.Status.IsFailure() {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		failures++
+		if failures == r.FailurePolicy.MaxFailures {
+			log.Warnf("Not starting any more tests after %d failure(s) (failure policy: %s)", failures, r.FailurePolicy)
+		}
+	}
+
+	// start runs a test unless the run was interrupted or the failure policy says that no more tests should be started.
+	start := func(test PlannedTest) RunnerResult {
+		if ctx.Err() != nil {
+			err := &TestSkippedError{Reason: "not started (run was interrupted)"}
+			return r.skipTest(runId, test, err)
+		} else if stopped() {
+			err := &TestSkippedError{Reason: fmt.Sprintf("not started (failure policy: %s)", r.FailurePolicy)}
+			return r.skipTest(runId, test, err)
+		}
+		return r.runTest(ctx, runId, test)
+	}
+
+	// Tests that can run concurrently share the lock, and tests that must run alone hold it exclusively. Waiting for the
+	// lock can take a long time, so whether the test should still be started is checked again once it's been acquired.
+	var lock sync.RWMutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for test := range queue {
+				var result RunnerResult
+				if ctx.Err() != nil || stopped() {
+					result = start(test)
+				} else if isExclusive(test) {
+					lock.Lock()
+					result = start(test)
+					lock.Unlock()
+				} else {
+					lock.RLock()
+					result = start(test)
+					lock.RUnlock()
+				}
+				recordResult(result)
+				results <- result
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+func (r *Runner) runTest(ctx context.Context, runId string, test PlannedTest) RunnerResult {
+	opts := MergeTestOptions(test.Options, r.Options)
+	result := r.RunInvocation(ctx, test.Test, r.newTestInvocation(runId, test.Test, opts))
+	result.Test = test
+	return result
+}
+
+// RunInvocation runs a single test as part of an existing test invocation (e.g. one that was requested by another
+// host). The invocation's options are used as-is.
+func (r *Runner) RunInvocation(ctx context.Context, test Test, invocation TestInvocation) RunnerResult {
+	emitter := r.invokeTest(invocation)
+	emitter.emitTestStatus(StatusTypeStarted, nil)
+
+	opts := MergeTestOptions(invocation.Options, TestOptions{InputArguments: invocation.InputArguments})
+	result, err := test.Run(withEventEmitter(ctx, emitter), r.AtomicsDir, opts)
+	if err != nil {
+		log.Errorf("Failed to execute test '%s': %s", test.GetDisplayName(), err)
+		result = NewTestResultFromError(test, err)
+	}
+	result.TestInvocationId = invocation.Id
+	result.RunId = invocation.RunId
+	result.Identity = r.Identity
+	r.completeTest(emitter, result)
+	return RunnerResult{
+		Test:   PlannedTest{Test: test, Options: *opts},
+		Result: result,
+		Err:    err,
+	}
+}
+
+func (r *Runner) skipTest(runId string, test PlannedTest, err error) RunnerResult {
+	result := r.AbortInvocation(test.Test, r.newTestInvocation(runId, test.Test, MergeTestOptions(test.Options, r.Options)), err)
+	result.Test = test
+	return result
+}
+
+// AbortInvocation completes a test invocation without running the test. The result is skipped if the error is a
+// TestSkippedError, and errored otherwise (e.g. if the agent that was running the test stopped before it completed).
+func (r *Runner) AbortInvocation(test Test, invocation TestInvocation, err error) RunnerResult {
+	emitter := r.invokeTest(invocation)
+	result := NewTestResultFromError(test, err)
+	result.TestInvocationId = invocation.Id
+	result.RunId = invocation.RunId
+	result.Identity = r.Identity
+	r.completeTest(emitter, result)
+	opts := MergeTestOptions(invocation.Options, TestOptions{InputArguments: invocation.InputArguments})
+	return RunnerResult{
+		Test:   PlannedTest{Test: test, Options: *opts},
+		Result: result,
+		Err:    err,
+	}
+}
+
+// newTestInvocation creates a test invocation shaped like docs/events/test_invocation.json (i.e. with the input
+// arguments alongside, rather than inside of, the options).
+func (r *Runner) newTestInvocation(runId string, test Test, opts *TestOptions) TestInvocation {
+	invocation := TestInvocation{
+		EventHeader:    NewEventHeader(r.Identity),
+		RunId:          runId,
+		TestId:         test.AutoGeneratedGuid,
+		InputArguments: opts.InputArguments,
+		Options:        *opts,
+	}
+	invocation.Options.InputArguments = nil
+	return invocation
+}
+
+// invokeTest emits a test invocation and returns an emitter for the rest of the invocation's events, or nil if events
+// aren't being collected.
+func (r *Runner) invokeTest(invocation TestInvocation) *eventEmitter {
+	var sink EventSink
+	if r.Events != nil && r.Store != nil {
+		sink = MultiEventSink{r.Events, r.Store}
+	} else if r.Events != nil {
+		sink = r.Events
+	} else if r.Store != nil {
+		sink = r.Store
+	} else {
+		return nil
+	}
+	emitter := &eventEmitter{
+		sink:         sink,
+		identity:     r.Identity,
+		testId:       invocation.TestId,
+		invocationId: invocation.Id,
+	}
+	emitter.emit(invocation)
+	return emitter
+}
+
+func (r *Runner) completeTest(emitter *eventEmitter, result *TestResult) {
+	if r.Store != nil {
+		if emitter != nil {
+			result.TestInvocationId = emitter.invocationId
+		}
+		err := r.Store.SaveResult(*result)
+		if err != nil {
+			log.Errorf("Failed to save result of test '%s': %s", result.Test.GetDisplayName(), err)
+		}
+	}
+	if emitter == nil {
+		return
+	}
+	result.TestInvocationId = emitter.invocationId
+	emitter.emitTestStatus(StatusTypeCompleted, result)
+	emitter.emitTestResult(result)
+}
@@ -0,0 +1,120 @@
+package atomic
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestParseFailurePolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		want    int
+		wantErr bool
+	}{
+		{policy: "", want: 0},
+		{policy: "continue", want: 0},
+		{policy: "stop", want: 1},
+		{policy: " Stop ", want: 1},
+		{policy: "stop-after-1", want: 1},
+		{policy: "stop-after-3", want: 3},
+		{policy: "STOP-AFTER-10", want: 10},
+		{policy: "stop-after-0", wantErr: true},
+		{policy: "stop-after--1", wantErr: true},
+		{policy: "stop-after-", wantErr: true},
+		{policy: "stop-after-x", wantErr: true},
+		{policy: "halt", wantErr: true},
+	}
+	for _, tt := range tests {
+		policy, err := ParseFailurePolicy(tt.policy)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %+v", tt.policy, policy)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.policy, err)
+			continue
+		}
+		if policy.MaxFailures != tt.want {
+			t.Errorf("%q: got %d maximum failures, want %d", tt.policy, policy.MaxFailures, tt.want)
+		}
+
+		// Failure policies must round-trip through their string representation.
+		parsed, err := ParseFailurePolicy(policy.String())
+		if err != nil || *parsed != *policy {
+			t.Errorf("%q: failed to parse %s: %v", tt.policy, policy, err)
+		}
+	}
+}
+
+func TestRunnerFailurePolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	// Each character is a test that either passes (p) or fails (f).
+	tests := []struct {
+		name        string
+		maxFailures int
+		outcomes    string
+		want        []TestStatus
+	}{
+		{
+			name:     "continue",
+			outcomes: "ffpf",
+			want:     []TestStatus{TestStatusFailed, TestStatusFailed, TestStatusPassed, TestStatusFailed},
+		},
+		{
+			name:        "stop",
+			maxFailures: 1,
+			outcomes:    "pfpp",
+			want:        []TestStatus{TestStatusPassed, TestStatusFailed, TestStatusSkipped, TestStatusSkipped},
+		},
+		{
+			name:        "stop after 2 failures",
+			maxFailures: 2,
+			outcomes:    "fpffp",
+			want:        []TestStatus{TestStatusFailed, TestStatusPassed, TestStatusFailed, TestStatusSkipped, TestStatusSkipped},
+		},
+		{
+			name:        "fewer failures than the maximum",
+			maxFailures: 2,
+			outcomes:    "pfp",
+			want:        []TestStatus{TestStatusPassed, TestStatusFailed, TestStatusPassed},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var plannedTests []PlannedTest
+			for i, outcome := range tt.outcomes {
+				command := "true"
+				if outcome == 'f' {
+					command = "false"
+				}
+				test := Test{
+					Name:               fmt.Sprintf("Test %d", i),
+					AutoGeneratedGuid:  fmt.Sprintf("%d", i),
+					SupportedPlatforms: []string{runtime.GOOS},
+					Executor:           Executor{Name: "sh", Command: command},
+				}
+				plannedTests = append(plannedTests, PlannedTest{Test: test, Options: *NewTestOptions()})
+			}
+			runner := NewRunner(t.TempDir(), 1)
+			runner.FailurePolicy = FailurePolicy{MaxFailures: tt.maxFailures}
+
+			got := make([]TestStatus, len(plannedTests))
+			for result := range runner.Run(context.Background(), plannedTests) {
+				i := slices.IndexFunc(plannedTests, func(test PlannedTest) bool {
+					return test.Test.AutoGeneratedGuid == result.Result.Test.AutoGeneratedGuid
+				})
+				got[i] = result.Result.Status
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,666 @@
+package atomic
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
+	"github.com/whitfieldsdad/go-building-blocks/pkg/bb"
+)
+
+const (
+	apiPrefix = "/api/v1"
+
+	InvocationStatusPending   = "pending"
+	InvocationStatusRunning   = "running"
+	InvocationStatusCompleted = "completed"
+
+	// DefaultMaxCompletedInvocations is the number of completed invocations (and their results) that are kept in memory
+	// by default. Results can be saved to the result store if they need to be kept for longer.
+	DefaultMaxCompletedInvocations = 1000
+)
+
+// InvocationStatus is the status of a test invocation that was submitted to the API.
+type InvocationStatus struct {
+	Invocation TestInvocation `json:"invocation" yaml:"invocation"`
+	Status     string         `json:"status" yaml:"status"`
+	Result     *TestResult    `json:"result,omitempty" yaml:"result,omitempty"`
+}
+
+// Server exposes tests, test plans, test invocations, and test results over HTTP.
+//
+// Submitted test plans are run in the background, one test at a time, and plans are run one after another. Every event
+// that is emitted while tests are running is available from the event stream (see handleEvents).
+type Server struct {
+	AtomicsDir string
+	Password   string
+	Runner     *Runner
+
+	// Auth authorizes requests. If nil, every request is allowed.
+	Auth *Authorizer
+
+	// TLS enables TLS (and optionally, mutual TLS). If nil, the API is served over plain HTTP.
+	TLS *TLSOptions
+
+	// MaxCompletedInvocations is the number of completed invocations to keep in memory. Once this limit is exceeded, the
+	// oldest completed invocations and their results are evicted. Pending and running invocations are never evicted.
+	MaxCompletedInvocations int
+
+	broker *EventBroker
+
+	// runMu ensures that only one test plan runs at a time.
+	runMu sync.Mutex
+
+	// testsMu guards the tests, which are read from the atomics directory once and then reused.
+	testsMu sync.Mutex
+	tests   []Test
+
+	mu                   sync.RWMutex
+	invocationIds        []string
+	invocations          map[string]*InvocationStatus
+	results              map[string]*TestResult
+	completedInvocations int
+}
+
+func NewServer(atomicsDir, password string) *Server {
+	s := &Server{
+		AtomicsDir:              atomicsDir,
+		Password:                password,
+		Runner:                  NewRunner(atomicsDir, 1),
+		MaxCompletedInvocations: DefaultMaxCompletedInvocations,
+		broker:                  NewEventBroker(256),
+		invocations:             make(map[string]*InvocationStatus),
+		results:                 make(map[string]*TestResult),
+	}
+	s.Runner.Events = MultiEventSink{EventSinkFunc(s.trackEvent), s.broker}
+	return s
+}
+
+// Handler returns the HTTP handler for the API. Tests are run using the provided context, so cancelling it interrupts
+// any running tests.
+func (s *Server) Handler(ctx context.Context) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(apiPrefix+"/tests", s.handleTests)
+	mux.HandleFunc(apiPrefix+"/tests/", s.handleTests)
+	mux.HandleFunc(apiPrefix+"/advertisement", s.handleAdvertisement)
+	mux.HandleFunc(apiPrefix+"/plans", func(w http.ResponseWriter, r *http.Request) {
+		s.handlePlans(ctx, w, r)
+	})
+	mux.HandleFunc(apiPrefix+"/invocations", s.handleInvocations)
+	mux.HandleFunc(apiPrefix+"/invocations/", s.handleInvocations)
+	mux.HandleFunc(apiPrefix+"/results", s.handleResults)
+	mux.HandleFunc(apiPrefix+"/results/", s.handleResults)
+	mux.HandleFunc(apiPrefix+"/diff", s.handleDiff)
+	mux.HandleFunc(apiPrefix+"/events", s.handleEvents)
+	return mux
+}
+
+// ListenAndServe serves the API until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	listen := func(server *http.Server) func() error {
+		return server.ListenAndServe
+	}
+	var tlsConfig *tls.Config
+	if s.TLS != nil {
+		var err error
+		tlsConfig, err = s.TLS.GetTLSConfig()
+		if err != nil {
+			return err
+		}
+		listen = func(server *http.Server) func() error {
+			return func() error {
+				return server.ListenAndServeTLS(s.TLS.CertFile, s.TLS.KeyFile)
+			}
+		}
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(ctx),
+		ReadHeaderTimeout: 10 * time.Second,
+
+		// Requests share the server's context so that event streams are closed when the server shuts down.
+		BaseContext: func(net.Listener) context.Context { return ctx },
+		TLSConfig:   tlsConfig,
+	}
+	return serve(ctx, server, listen(server))
+}
+
+// serve runs the provided server until it fails or the context is cancelled.
+func serve(ctx context.Context, server *http.Server, listen func() error) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- listen()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		return err
+	}
+	err = <-errs
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// authorize writes an error response and returns false if the request isn't allowed to do something that requires the
+// provided scope.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, scope Scope) bool {
+	if s.Auth == nil {
+		return true
+	}
+	err := s.Auth.Authorize(r, scope)
+	if err != nil {
+		if err == ErrMissingToken || err == ErrInvalidToken {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, err)
+		} else {
+			writeError(w, http.StatusForbidden, err)
+		}
+		return false
+	}
+	return true
+}
+
+// readTests returns the tests that match the filter. Tests are only read from the atomics directory on first use, so the
+// server must be restarted to pick up changes to the atomics directory.
+func (s *Server) readTests(filter *TestFilter) ([]Test, error) {
+	s.testsMu.Lock()
+	defer s.testsMu.Unlock()
+	if s.tests == nil {
+		tests, err := ReadTests(s.AtomicsDir, s.Password, nil)
+		if err != nil {
+			return nil, err
+		}
+		s.tests = nonNil(tests)
+	}
+	return filterTests(s.tests, filter), nil
+}
+
+// GET /tests, GET /tests/count, and GET /tests/{id}
+func (s *Server) handleTests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if !s.authorize(w, r, ScopeList) {
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix+"/tests"), "/")
+	filter, err := parseTestFilterQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if id != "" && id != "count" {
+		filter = &TestFilter{Ids: []string{id}}
+	}
+	tests, err := s.readTests(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	switch id {
+	case "":
+		writeJSON(w, http.StatusOK, nonNil(tests))
+	case "count":
+		writeJSON(w, http.StatusOK, map[string]int{"total": len(tests)})
+	default:
+		if len(tests) == 0 {
+			writeError(w, http.StatusNotFound, errors.Errorf("test not found: %s", id))
+			return
+		}
+		writeJSON(w, http.StatusOK, tests[0])
+	}
+}
+
+// parseTestFilterQuery reads a test filter from query parameters named after the fields of TestFilter. List parameters
+// can be repeated or comma-separated.
+func parseTestFilterQuery(r *http.Request) (*TestFilter, error) {
+	query := r.URL.Query()
+	getList := func(key string) []string {
+		var values []string
+		for _, value := range query[key] {
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					values = append(values, v)
+				}
+			}
+		}
+		return values
+	}
+	getBool := func(key string) (*bool, error) {
+		value := query.Get(key)
+		if value == "" {
+			return nil, nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.Errorf("invalid value for %s: %s", key, value)
+		}
+		return &b, nil
+	}
+	filter := &TestFilter{
+		Ids:                getList("id"),
+		Names:              getList("name"),
+		Descriptions:       getList("description"),
+		Platforms:          getList("platform"),
+		ExecutorTypes:      getList("executor_type"),
+		AttackTechniqueIds: getList("attack_technique_id"),
+	}
+	var err error
+	filter.ElevationRequired, err = getBool("elevation_required")
+	if err != nil {
+		return nil, err
+	}
+	filter.ReferencesAtomicsFolder, err = getBool("references_atomics_folder")
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// GET /advertisement
+//
+// Tests can be filtered using the same query parameters as GET /tests. If check_dependencies is true, the prerequisite
+// command of each dependency is run, which requires the "run" scope.
+func (s *Server) handleAdvertisement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	opts := &AdvertisementOptions{DependencyTimeout: s.Runner.Options.GetDependencyTimeout()}
+	if value := r.URL.Query().Get("check_dependencies"); value != "" {
+		var err error
+		opts.CheckDependencies, err = strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.Errorf("invalid value for check_dependencies: %s", value))
+			return
+		}
+	}
+	scope := ScopeList
+	if opts.CheckDependencies {
+		scope = ScopeRun
+	}
+	if !s.authorize(w, r, scope) {
+		return
+	}
+	filter, err := parseTestFilterQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	tests, err := s.readTests(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	advertisement, err := AdvertiseTests(r.Context(), s.AtomicsDir, tests, s.Runner.Identity, opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	advertisement.Tests = nonNil(advertisement.Tests)
+	writeJSON(w, http.StatusOK, advertisement)
+}
+
+// POST /plans
+//
+// The request body is a test plan in any of the supported formats (see ParseTestPlan). The selected tests are queued,
+// and their invocations are returned so that their progress can be polled.
+//
+// Test plans that select tests which require elevation require the "run-elevated" scope, and test plans that set input
+// arguments or test options require the "run-with-options" scope.
+func (s *Server) handlePlans(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if !s.authorize(w, r, ScopeRun) {
+		return
+	}
+	plan, err := s.readTestPlan(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if hasTestOptions(plan) && !s.authorize(w, r, ScopeRunWithOptions) {
+		return
+	}
+	plannedTests, err := s.planTests(plan)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	requiresElevation := slices.ContainsFunc(plannedTests, func(test PlannedTest) bool {
+		return test.Test.RequiresElevation()
+	})
+	if requiresElevation && !s.authorize(w, r, ScopeRunElevated) {
+		return
+	}
+	invocations := s.queueTests(ctx, plannedTests)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"invocations": invocations})
+}
+
+func (s *Server) readTestPlan(body io.Reader) (TestPlanInterface, error) {
+	var data map[string]interface{}
+	err := json.NewDecoder(io.LimitReader(body, 10<<20)).Decode(&data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse test plan")
+	}
+	plan, err := ParseTestPlan(data)
+	if err != nil {
+		return nil, err
+	}
+	atomicsDir := plan.GetAtomicsDir()
+	if atomicsDir != "" && filepath.Clean(atomicsDir) != filepath.Clean(s.AtomicsDir) {
+		return nil, errors.Errorf("test plan uses a different atomics directory than the server: %s", atomicsDir)
+	}
+	return plan, nil
+}
+
+// hasTestOptions returns true if a test plan sets input arguments or test options for any of the tests that it selects.
+func hasTestOptions(plan TestPlanInterface) bool {
+	if !plan.GetTestOptions().IsZero() {
+		return true
+	}
+	return slices.ContainsFunc(plan.GetEntries(), func(entry TestPlanEntry) bool {
+		return !entry.Options.IsZero()
+	})
+}
+
+func (s *Server) planTests(plan TestPlanInterface) ([]PlannedTest, error) {
+	tests, err := s.readTests(nil)
+	if err != nil {
+		return nil, err
+	}
+	plannedTests, err := SelectTests(tests, plan)
+	if err != nil {
+		return nil, err
+	}
+	if len(plannedTests) == 0 {
+		return nil, errors.New("test plan didn't select any tests")
+	}
+	return plannedTests, nil
+}
+
+func (s *Server) queueTests(ctx context.Context, plannedTests []PlannedTest) []TestInvocation {
+	var invocations []TestInvocation
+	runId := bb.NewUUID4()
+	s.mu.Lock()
+	for _, test := range plannedTests {
+		invocation := s.Runner.newTestInvocation(runId, test.Test, MergeTestOptions(test.Options, s.Runner.Options))
+		invocations = append(invocations, invocation)
+		s.invocationIds = append(s.invocationIds, invocation.Id)
+		s.invocations[invocation.Id] = &InvocationStatus{Invocation: invocation, Status: InvocationStatusPending}
+	}
+	s.mu.Unlock()
+
+	go func() {
+		s.runMu.Lock()
+		defer s.runMu.Unlock()
+		for i, test := range plannedTests {
+			if ctx.Err() != nil {
+				err := &TestSkippedError{Reason: "not started (server is shutting down)"}
+				result := NewTestResultFromError(test.Test, err)
+				result.RunId = runId
+				s.setResult(invocations[i].Id, result)
+				continue
+			}
+			result := s.Runner.RunInvocation(ctx, test.Test, invocations[i])
+			s.setResult(invocations[i].Id, result.Result)
+		}
+	}()
+	return invocations
+}
+
+// trackEvent keeps track of the status of each invocation as events are emitted.
+func (s *Server) trackEvent(event Event) error {
+	if event, ok := event.(TestStatusEvent); ok && event.StatusType == StatusTypeStarted {
+		s.mu.Lock()
+		if status, ok := s.invocations[event.TestInvocationId]; ok {
+			status.Status = InvocationStatusRunning
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+func (s *Server) setResult(invocationId string, result *TestResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result.TestInvocationId = invocationId
+	s.results[result.Id] = result
+	if status, ok := s.invocations[invocationId]; ok {
+		status.Status = InvocationStatusCompleted
+		status.Result = result
+		s.completedInvocations++
+	}
+	s.evictCompletedInvocations()
+}
+
+// evictCompletedInvocations removes the oldest completed invocations and their results once there are more than
+// MaxCompletedInvocations of them. The caller must hold the lock.
+func (s *Server) evictCompletedInvocations() {
+	if s.MaxCompletedInvocations <= 0 || s.completedInvocations <= s.MaxCompletedInvocations {
+		return
+	}
+	s.invocationIds = slices.DeleteFunc(s.invocationIds, func(id string) bool {
+		status := s.invocations[id]
+		if s.completedInvocations <= s.MaxCompletedInvocations || status.Status != InvocationStatusCompleted {
+			return false
+		}
+		delete(s.invocations, id)
+		if status.Result != nil {
+			delete(s.results, status.Result.Id)
+		}
+		s.completedInvocations--
+		return true
+	})
+}
+
+// GET /invocations and GET /invocations/{id}
+func (s *Server) handleInvocations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if !s.authorize(w, r, ScopeList) {
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix+"/invocations"), "/")
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if id != "" {
+		status, ok := s.invocations[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, errors.Errorf("test invocation not found: %s", id))
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+		return
+	}
+	statuses := []InvocationStatus{}
+	for _, id := range s.invocationIds {
+		statuses = append(statuses, *s.invocations[id])
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// GET /results and GET /results/{id}
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if !s.authorize(w, r, ScopeList) {
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix+"/results"), "/")
+	statuses := r.URL.Query()["status"]
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if id != "" {
+		result, ok := s.results[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, errors.Errorf("test result not found: %s", id))
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	results := []TestResult{}
+	for _, invocationId := range s.invocationIds {
+		result := s.invocations[invocationId].Result
+		if result == nil {
+			continue
+		}
+		if len(statuses) > 0 && !containsStatus(statuses, result.Status) {
+			continue
+		}
+		results = append(results, *result)
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// GET /diff?a={run-id}&b={run-id}
+//
+// Compares the results of two runs (i.e. of two submitted test plans).
+func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if !s.authorize(w, r, ScopeList) {
+		return
+	}
+	query := r.URL.Query()
+	runA, runB := query.Get("a"), query.Get("b")
+	if runA == "" || runB == "" {
+		writeError(w, http.StatusBadRequest, errors.New("two run IDs must be provided (a and b)"))
+		return
+	}
+	resultsA, err := s.getRunResults(runA)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	resultsB, err := s.getRunResults(runB)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, DiffTestResults(resultsA, resultsB))
+}
+
+// getRunResults returns the results of a run from the result store, if there is one, or from the results of the runs
+// that were submitted since the server was started.
+func (s *Server) getRunResults(runId string) ([]TestResult, error) {
+	if s.Runner.Store != nil {
+		return s.Runner.Store.GetRun(runId)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var results []TestResult
+	for _, result := range s.results {
+		if result.RunId == runId {
+			results = append(results, *result)
+		}
+	}
+	if len(results) == 0 {
+		return nil, errors.Errorf("run not found: %s", runId)
+	}
+	return results, nil
+}
+
+func containsStatus(statuses []string, status TestStatus) bool {
+	for _, s := range statuses {
+		if TestStatus(s) == status {
+			return true
+		}
+	}
+	return false
+}
+
+// GET /events
+//
+// Streams events as server-sent events, named after the type of each event.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if !s.authorize(w, r, ScopeList) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	events, unsubscribe := s.broker.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			blob, err := json.Marshal(event)
+			if err != nil {
+				log.Errorf("Failed to encode %s event: %s", event.GetEventType(), err)
+				continue
+			}
+			_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.GetEventHeader().Id, event.GetEventType(), blob)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Errorf("Failed to write response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -46,10 +46,9 @@ func (f TestFilter) Matches(t Test) bool {
 			if !matches {
 				return false
 			}
-			return false
 		}
 	}
-	if f.ElevationRequired != nil && *f.ElevationRequired != t.Executor.ElevationRequired {
+	if f.ElevationRequired != nil && *f.ElevationRequired != t.RequiresElevation() {
 		return false
 	}
 	if f.ReferencesAtomicsFolder != nil && *f.ReferencesAtomicsFolder != t.HasReferencesToAtomicsFolder() {
@@ -79,6 +78,12 @@ func MergeTestFilters(filters ...TestFilter) *TestFilter {
 		combined.Platforms = append(combined.Platforms, filter.Platforms...)
 		combined.ExecutorTypes = append(combined.ExecutorTypes, filter.ExecutorTypes...)
 		combined.AttackTechniqueIds = append(combined.AttackTechniqueIds, filter.AttackTechniqueIds...)
+		if filter.ElevationRequired != nil {
+			combined.ElevationRequired = filter.ElevationRequired
+		}
+		if filter.ReferencesAtomicsFolder != nil {
+			combined.ReferencesAtomicsFolder = filter.ReferencesAtomicsFolder
+		}
 	}
 	return combined
 }
@@ -3,51 +3,219 @@ package atomic
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/charmbracelet/log"
+	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 )
 
 type TestPlanInterface interface {
+	GetAtomicsDir() string
 	GetTestFilters() []TestFilter
 	GetTestOptions() TestOptions
+	GetEntries() []TestPlanEntry
+}
+
+// TestPlanEntry pairs a test filter with the options to use when running the tests that it matches.
+//
+// Tests matched by an excluded entry are not selected, even if a later entry would have matched them.
+type TestPlanEntry struct {
+	Filter   TestFilter  `json:"filter" yaml:"filter"`
+	Options  TestOptions `json:"options" yaml:"options"`
+	Excluded bool        `json:"excluded,omitempty" yaml:"excluded,omitempty"`
+}
+
+// PlannedTest is a test that was selected by a test plan, along with the options that it should be run with.
+type PlannedTest struct {
+	Test    Test        `json:"test" yaml:"test"`
+	Options TestOptions `json:"options" yaml:"options"`
+}
+
+// SelectTests returns the tests that are matched by the entries of the provided test plans.
+//
+// The entries of each plan are applied in order, and the first entry that matches a test determines the options that
+// the test will be run with. If no test plans are provided, every test is selected. Tests that are selected by more
+// than one plan are only run once, and an error is returned if the plans select them with different options.
+//
+// Input arguments are validated against the selected tests: input arguments that are set for a whole plan must be
+// accepted by at least one of the tests selected by that plan, and input arguments that are set for an entry must be
+// accepted by at least one of the tests matched by that entry. Each test only receives the input arguments that it
+// accepts, coerced to the appropriate types.
+func SelectTests(tests []Test, plans ...TestPlanInterface) ([]PlannedTest, error) {
+	var selected []PlannedTest
+	if len(plans) == 0 {
+		for _, test := range tests {
+			selected = append(selected, PlannedTest{Test: test, Options: *NewTestOptions()})
+		}
+		return selected, nil
+	}
+	var errs []InputArgumentError
+	selectedIndexes := make(map[string]int)
+	for _, plan := range plans {
+		entries := plan.GetEntries()
+		matchesByEntry := make([][]Test, len(entries))
+		var matches []Test
+		for _, test := range tests {
+			for i, entry := range entries {
+				if entry.Filter.Matches(test) {
+					if !entry.Excluded {
+						matchesByEntry[i] = append(matchesByEntry[i], test)
+						matches = append(matches, test)
+					}
+					break
+				}
+			}
+		}
+
+		// Check for input arguments that aren't accepted by any of the matching tests (e.g. due to typos).
+		planArgs := getMapKeys(plan.GetTestOptions().InputArguments)
+		if len(matches) > 0 {
+			errs = append(errs, getUnknownArgs(planArgs, matches)...)
+		}
+		for i, entry := range entries {
+			if len(matchesByEntry[i]) == 0 {
+				continue
+			}
+			entryArgs := getMapKeys(entry.Options.InputArguments).Difference(planArgs)
+			errs = append(errs, getUnknownArgs(entryArgs, matchesByEntry[i])...)
+
+			for _, test := range matchesByEntry[i] {
+				opts := entry.Options
+				opts.InputArguments = make(map[string]interface{})
+				for k, v := range entry.Options.InputArguments {
+					if _, ok := test.InputArguments[k]; ok {
+						opts.InputArguments[k] = v
+					}
+				}
+				inputArguments, err := test.ValidateInputArguments(opts.InputArguments)
+				if err != nil {
+					errs = append(errs, err.(*InputArgumentsError).Errors...)
+					continue
+				}
+				opts.InputArguments = inputArguments
+				if j, ok := selectedIndexes[test.AutoGeneratedGuid]; ok {
+					if !reflect.DeepEqual(selected[j].Options, opts) {
+						return nil, errors.Errorf("test %s is selected by more than one test plan with different options", test.AutoGeneratedGuid)
+					}
+					continue
+				}
+				selectedIndexes[test.AutoGeneratedGuid] = len(selected)
+				selected = append(selected, PlannedTest{Test: test, Options: opts})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &InputArgumentsError{Errors: errs}
+	}
+	return selected, nil
+}
+
+// getUnknownArgs returns an error for each of the named input arguments that isn't accepted by any of the tests.
+func getUnknownArgs(names mapset.Set[string], tests []Test) []InputArgumentError {
+	var errs []InputArgumentError
+	for _, name := range names.ToSlice() {
+		known := slices.ContainsFunc(tests, func(test Test) bool {
+			_, ok := test.InputArguments[name]
+			return ok
+		})
+		if !known {
+			err := InputArgumentError{Name: name, Reason: "not accepted by any of the selected tests"}
+			if len(tests) == 1 {
+				err.TestId = tests[0].AutoGeneratedGuid
+				err.Reason = "unknown input argument"
+			}
+			errs = append(errs, err)
+		}
+	}
+	slices.SortFunc(errs, func(a, b InputArgumentError) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return errs
 }
 
 type BulkTestPlan struct {
-	Tests          []TestFilter           `json:"tests" yaml:"tests"`
-	InputArguments map[string]interface{} `json:"input_arguments" yaml:"input_arguments"`
+	AtomicsDir string `json:"atomics_dir,omitempty" yaml:"atomics_dir,omitempty"`
+	TestFilter
+	TestOptions
+	Tests []bulkTestPlanEntry `json:"tests" yaml:"tests"`
+}
+
+type bulkTestPlanEntry struct {
+	TestFilter
+	TestOptions
+}
+
+// GetAtomicsDir returns the path to the atomics directory that the tests should be read from, or an empty string if the
+// plan doesn't specify one.
+func (plan BulkTestPlan) GetAtomicsDir() string {
+	return expandHomeDir(plan.AtomicsDir)
 }
 
 func (plan BulkTestPlan) GetTestFilters() []TestFilter {
 	var filters []TestFilter
-	filters = append(filters, plan.Tests...)
+	for _, entry := range plan.GetEntries() {
+		filters = append(filters, entry.Filter)
+	}
 	return filters
 }
 
 func (plan BulkTestPlan) GetTestOptions() TestOptions {
-	return TestOptions{
-		InputArguments: plan.InputArguments,
+	return plan.TestOptions
+}
+
+func (plan BulkTestPlan) GetEntries() []TestPlanEntry {
+	if len(plan.Tests) == 0 {
+		return []TestPlanEntry{{Filter: plan.TestFilter, Options: plan.GetTestOptions()}}
+	}
+	var entries []TestPlanEntry
+	for _, test := range plan.Tests {
+		entries = append(entries, TestPlanEntry{
+			Filter:  *MergeTestFilters(plan.TestFilter, test.TestFilter),
+			Options: *MergeTestOptions(plan.GetTestOptions(), test.TestOptions),
+		})
 	}
+	return entries
 }
 
 type TestPlan struct {
-	Tests          []testReference        `json:"tests" yaml:"tests"`
-	InputArguments map[string]interface{} `json:"input_arguments" yaml:"input_arguments"`
+	AtomicsDir string `json:"atomics_dir,omitempty" yaml:"atomics_dir,omitempty"`
+	TestFilter
+	TestOptions
+	Tests []testReference `json:"tests" yaml:"tests"`
+}
+
+// GetAtomicsDir returns the path to the atomics directory that the tests should be read from, or an empty string if the
+// plan doesn't specify one.
+func (plan TestPlan) GetAtomicsDir() string {
+	return expandHomeDir(plan.AtomicsDir)
 }
 
 func (plan TestPlan) GetTestFilters() []TestFilter {
 	var filters []TestFilter
-	for _, test := range plan.Tests {
-		filters = append(filters, test.GetTestFilter())
+	for _, entry := range plan.GetEntries() {
+		filters = append(filters, entry.Filter)
 	}
 	return filters
 }
 
 func (plan TestPlan) GetTestOptions() TestOptions {
-	return TestOptions{
-		InputArguments: plan.InputArguments,
+	return plan.TestOptions
+}
+
+func (plan TestPlan) GetEntries() []TestPlanEntry {
+	var entries []TestPlanEntry
+	for _, test := range plan.Tests {
+		entries = append(entries, TestPlanEntry{
+			Filter:  *MergeTestFilters(plan.TestFilter, test.GetTestFilter()),
+			Options: *MergeTestOptions(plan.GetTestOptions(), test.TestOptions),
+		})
 	}
+	return entries
 }
 
 type testReference struct {
@@ -57,6 +225,7 @@ type testReference struct {
 	Platforms         []string `json:"platforms" yaml:"platforms"`
 	ElevationRequired *bool    `json:"elevation_required" yaml:"elevation_required"`
 	AttackTechniqueId string   `json:"attack_technique_id" yaml:"attack_technique_id"`
+	TestOptions
 }
 
 func (t testReference) GetTestFilter() TestFilter {
@@ -80,6 +249,36 @@ func (t testReference) GetTestFilter() TestFilter {
 	return f
 }
 
+// GetTestPlanAtomicsDir returns the atomics directory specified by the provided test plans, or an empty string if none
+// of them specify one. An error is returned if the test plans specify different atomics directories.
+func GetTestPlanAtomicsDir(plans ...TestPlanInterface) (string, error) {
+	atomicsDir := ""
+	for _, plan := range plans {
+		dir := plan.GetAtomicsDir()
+		if dir == "" {
+			continue
+		}
+		if atomicsDir != "" && filepath.Clean(dir) != filepath.Clean(atomicsDir) {
+			return "", errors.Errorf("test plans use different atomics directories: %s, %s", atomicsDir, dir)
+		}
+		atomicsDir = dir
+	}
+	return atomicsDir, nil
+}
+
+// expandHomeDir replaces a leading ~ in a path with the current user's home directory.
+func expandHomeDir(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Warnf("Failed to expand %s: %s", path, err)
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
 // ReadTestPlan reads a test plan from a file.
 func ReadTestPlan(path string) (TestPlanInterface, error) {
 	log.Infof("Reading test plan: %s", path)
@@ -99,18 +298,43 @@ func ReadTestPlan(path string) (TestPlanInterface, error) {
 	return plan, nil
 }
 
+// ReadTestPlans reads test plans from one or more files.
+func ReadTestPlans(paths []string) ([]TestPlanInterface, error) {
+	var plans []TestPlanInterface
+	for _, path := range paths {
+		plan, err := ReadTestPlan(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read test plan: %s", path)
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
 func ParseTestPlan(data map[string]interface{}) (TestPlanInterface, error) {
-	if isAttackNavigatorLayer(data) {
-		panic("not implemented")
+	if isNavigatorLayerPlan(data) {
+		log.Info("Parsing test plan as an ATT&CK Navigator layer plan")
+		plan, err := parseNavigatorLayerPlan(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse ATT&CK Navigator layer plan")
+		}
+		return *plan, nil
+	} else if isAttackNavigatorLayer(data) {
+		log.Info("Parsing test plan as an ATT&CK Navigator layer")
+		layer, err := parseNavigatorLayer(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse ATT&CK Navigator layer")
+		}
+		return *NewNavigatorLayerPlan(*layer), nil
 	} else {
 		return parseTestPlan(data)
 	}
 }
 
 func isAttackNavigatorLayer(data map[string]interface{}) bool {
-	_, ok := data["techniques"]
+	techniques, ok := data["techniques"].([]interface{})
 	if ok {
-		for _, technique := range data["techniques"].([]interface{}) {
+		for _, technique := range techniques {
 			m, _ := technique.(map[string]interface{})
 			if _, ok := m["techniqueID"]; ok {
 				return true
@@ -121,21 +345,47 @@ func isAttackNavigatorLayer(data map[string]interface{}) bool {
 }
 
 func parseTestPlan(data map[string]interface{}) (TestPlanInterface, error) {
-	var plan TestPlanInterface
-	var err error
-
-	// Parse the test plan as either a multi-test plan or as a bulk test plan.
-	testPlanFields := getMapKeys(data)
-	testReferenceFields, _, testFilterFields := diffStructFields(testReference{}, TestFilter{})
+	// Parse the test plan as either a multi-test plan or as a bulk test plan based on the fields used by its tests.
+	testPlanFields := mapset.NewSet[string]()
+	entries, _ := data["tests"].([]interface{})
+	for _, entry := range entries {
+		m, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("malformed test plan entry")
+		}
+		testPlanFields = testPlanFields.Union(getMapKeys(m))
+	}
+	testReferenceFields, sharedFields, bulkTestPlanEntryFields := diffStructFields(testReference{}, bulkTestPlanEntry{})
 
-	if testPlanFields.IsSubset(testReferenceFields) {
+	if len(entries) > 0 && testPlanFields.IsSubset(testReferenceFields.Union(sharedFields)) {
 		log.Info("Parsing test plan as a multi-test plan")
-		err = mapstructure.Decode(data, &plan)
-	} else if testPlanFields.IsSubset(testFilterFields) {
+		plan := &TestPlan{}
+		err := decodeTestPlan(data, plan)
+		if err != nil {
+			return nil, err
+		}
+		return *plan, nil
+	} else if testPlanFields.IsSubset(bulkTestPlanEntryFields.Union(sharedFields)) {
 		log.Info("Parsing test plan as a bulk test plan")
-		err = mapstructure.Decode(data, &plan)
-	} else {
-		return nil, errors.New("failed to determine test plan type")
+		plan := &BulkTestPlan{}
+		err := decodeTestPlan(data, plan)
+		if err != nil {
+			return nil, err
+		}
+		return *plan, nil
+	}
+	return nil, errors.New("failed to determine test plan type")
+}
+
+func decodeTestPlan(data map[string]interface{}, plan interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName:     "json",
+		Squash:      true,
+		ErrorUnused: true,
+		Result:      plan,
+	})
+	if err != nil {
+		return err
 	}
-	return plan, err
+	return decoder.Decode(data)
 }
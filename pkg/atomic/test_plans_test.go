@@ -0,0 +1,155 @@
+package atomic
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// entriesPlan is a test plan with explicit entries.
+type entriesPlan struct {
+	opts    TestOptions
+	entries []TestPlanEntry
+}
+
+func (p entriesPlan) GetAtomicsDir() string        { return "" }
+func (p entriesPlan) GetTestFilters() []TestFilter { return nil }
+func (p entriesPlan) GetTestOptions() TestOptions  { return p.opts }
+func (p entriesPlan) GetEntries() []TestPlanEntry  { return p.entries }
+
+func TestSelectTests(t *testing.T) {
+	tests := []Test{
+		{
+			Name:              "ps",
+			AutoGeneratedGuid: "1",
+			AttackTechniqueId: "T1057",
+			InputArguments:    map[string]ArgSpec{"count": {Type: "integer", DefaultValue: "3"}},
+		},
+		{
+			Name:              "top",
+			AutoGeneratedGuid: "2",
+			AttackTechniqueId: "T1057",
+		},
+		{
+			Name:              "dump",
+			AutoGeneratedGuid: "3",
+			AttackTechniqueId: "T1003.001",
+			InputArguments:    map[string]ArgSpec{"output_file": {Type: "path", DefaultValue: "/tmp/dump"}},
+		},
+	}
+	byTechnique := func(id string, opts TestOptions) TestPlanEntry {
+		return TestPlanEntry{Filter: TestFilter{AttackTechniqueIds: []string{id}}, Options: opts}
+	}
+	byId := func(id string, opts TestOptions) TestPlanEntry {
+		return TestPlanEntry{Filter: TestFilter{Ids: []string{id}}, Options: opts}
+	}
+	timeout := func(seconds float64) TestOptions {
+		return TestOptions{Timeout: seconds}
+	}
+	args := func(kv ...interface{}) TestOptions {
+		opts := TestOptions{InputArguments: make(map[string]interface{})}
+		for i := 0; i < len(kv); i += 2 {
+			opts.InputArguments[kv[i].(string)] = kv[i+1]
+		}
+		return opts
+	}
+
+	type selection struct {
+		id   string
+		opts TestOptions
+	}
+	testCases := []struct {
+		name    string
+		plans   []TestPlanInterface
+		want    []selection
+		wantErr bool
+	}{
+		{
+			name: "no plans selects every test",
+			want: []selection{{"1", args()}, {"2", args()}, {"3", args()}},
+		},
+		{
+			name:  "first matching entry determines the options",
+			plans: []TestPlanInterface{entriesPlan{entries: []TestPlanEntry{byId("1", timeout(5)), byTechnique("T1057", timeout(10))}}},
+			want:  []selection{{"1", timeout(5)}, {"2", timeout(10)}},
+		},
+		{
+			name: "excluded entries take precedence over later entries",
+			plans: []TestPlanInterface{entriesPlan{entries: []TestPlanEntry{
+				{Filter: TestFilter{Ids: []string{"2"}}, Excluded: true},
+				byTechnique("T1057", TestOptions{}),
+			}}},
+			want: []selection{{"1", args()}},
+		},
+		{
+			name: "tests selected by several plans with the same options are selected once",
+			plans: []TestPlanInterface{
+				entriesPlan{entries: []TestPlanEntry{byTechnique("T1057", timeout(5))}},
+				entriesPlan{entries: []TestPlanEntry{byId("1", timeout(5)), byTechnique("T1003*", TestOptions{})}},
+			},
+			want: []selection{{"1", timeout(5)}, {"2", timeout(5)}, {"3", args()}},
+		},
+		{
+			name: "tests selected by several plans with different options",
+			plans: []TestPlanInterface{
+				entriesPlan{entries: []TestPlanEntry{byTechnique("T1057", timeout(5))}},
+				entriesPlan{entries: []TestPlanEntry{byId("1", timeout(10))}},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "input arguments are coerced and only passed to tests that accept them",
+			plans: []TestPlanInterface{entriesPlan{entries: []TestPlanEntry{byTechnique("T1057", args("count", "5"))}}},
+			want:  []selection{{"1", args("count", 5)}, {"2", args()}},
+		},
+		{
+			name:    "unknown input arguments",
+			plans:   []TestPlanInterface{entriesPlan{entries: []TestPlanEntry{byTechnique("T1057", args("cuont", 5))}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid input arguments",
+			plans:   []TestPlanInterface{entriesPlan{entries: []TestPlanEntry{byId("1", args("count", "many"))}}},
+			wantErr: true,
+		},
+		{
+			name:  "plans that don't match any tests",
+			plans: []TestPlanInterface{entriesPlan{entries: []TestPlanEntry{byTechnique("T1059", TestOptions{})}}},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := SelectTests(tests, tt.plans...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", selected)
+				}
+				var inputArgumentsErr *InputArgumentsError
+				if errors.As(err, &inputArgumentsErr) && len(inputArgumentsErr.Errors) == 0 {
+					t.Errorf("expected input argument errors to be listed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var got []selection
+			for _, plannedTest := range selected {
+				opts := plannedTest.Options
+				if len(opts.InputArguments) == 0 {
+					opts.InputArguments = make(map[string]interface{})
+				}
+				got = append(got, selection{plannedTest.Test.AutoGeneratedGuid, opts})
+			}
+			want := tt.want
+			for i := range want {
+				if want[i].opts.InputArguments == nil {
+					want[i].opts.InputArguments = make(map[string]interface{})
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
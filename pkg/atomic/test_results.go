@@ -2,20 +2,58 @@ package atomic
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/whitfieldsdad/go-building-blocks/pkg/bb"
 )
 
+type TestStatus string
+
+const (
+	TestStatusPassed   TestStatus = "passed"
+	TestStatusFailed   TestStatus = "failed"
+	TestStatusSkipped  TestStatus = "skipped"
+	TestStatusErrored  TestStatus = "errored"
+	TestStatusTimedOut TestStatus = "timed_out"
+)
+
+// IsFailure returns true if the status counts towards the failures of a run (i.e. failed, errored, or timed out).
+func (s TestStatus) IsFailure() bool {
+	return s == TestStatusFailed || s == TestStatusErrored || s == TestStatusTimedOut
+}
+
+// TestSkippedError is returned when a test can't be run on the current system (e.g. because it requires elevation).
+type TestSkippedError struct {
+	Reason string
+}
+
+func (e TestSkippedError) Error() string {
+	return e.Reason
+}
+
 type TestResult struct {
-	Id               string                       `json:"id" yaml:"id"`
-	Time             time.Time                    `json:"time" yaml:"time"`
-	Test             Test                         `json:"test" yaml:"test"`
-	ExecutedCommands []bb.ExecutedCommand         `json:"executed_commands" yaml:"executed_commands"`
-	Dependencies     []DependencyResolutionResult `json:"dependencies,omitempty" yaml:"dependencies"`
+	Id                  string                       `json:"id" yaml:"id"`
+	Time                time.Time                    `json:"time" yaml:"time"`
+	TestInvocationId    string                       `json:"test_invocation_id,omitempty" yaml:"test_invocation_id,omitempty"`
+	RunId               string                       `json:"run_id,omitempty" yaml:"run_id,omitempty"`
+	Status              TestStatus                   `json:"status" yaml:"status"`
+	Reason              string                       `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Test                Test                         `json:"test" yaml:"test"`
+	AttackTechniqueId   string                       `json:"attack_technique_id,omitempty" yaml:"attack_technique_id,omitempty"`
+	AttackTechniqueName string                       `json:"attack_technique_name,omitempty" yaml:"attack_technique_name,omitempty"`
+	ExecutedCommands    []ExecutedCommand            `json:"executed_commands" yaml:"executed_commands"`
+	Dependencies        []DependencyResolutionResult `json:"dependencies,omitempty" yaml:"dependencies"`
+	TimedOut            bool                         `json:"timed_out" yaml:"timed_out"`
+	CleanupTimedOut     bool                         `json:"cleanup_timed_out,omitempty" yaml:"cleanup_timed_out,omitempty"`
+
+	// Identity identifies the agent, host, and user that the test was run by.
+	Identity `yaml:",inline"`
 }
 
-func NewTestResult(testId string, test Test, executedCommands []bb.ExecutedCommand) (*TestResult, error) {
+func NewTestResult(testId string, test Test, executedCommands []ExecutedCommand) (*TestResult, error) {
 	if testId == "" {
 		return nil, errors.New("missing test ID")
 	}
@@ -28,12 +66,36 @@ func NewTestResult(testId string, test Test, executedCommands []bb.ExecutedComma
 			startTime = &executedCommand.StartTime
 		}
 	}
+	result := newTestResult(test, *startTime)
+	result.ExecutedCommands = executedCommands
+	result.Status, result.Reason = result.getStatus()
+	return result, nil
+}
+
+// NewTestResultFromError returns the result of a test that couldn't be run. Tests that were skipped (see
+// TestSkippedError) have a status of "skipped", and all other tests have a status of "errored".
+func NewTestResultFromError(test Test, err error) *TestResult {
+	status := TestStatusErrored
+	var skipped *TestSkippedError
+	if errors.As(err, &skipped) {
+		status = TestStatusSkipped
+	}
+	result := newTestResult(test, time.Now())
+	result.Status = status
+	result.Reason = err.Error()
+	return result
+}
+
+// newTestResult returns an empty result for a test that was started at the provided time.
+func newTestResult(test Test, startTime time.Time) *TestResult {
 	return &TestResult{
-		Id:               bb.NewUUID4(),
-		Time:             *startTime,
-		Test:             test,
-		ExecutedCommands: executedCommands,
-	}, nil
+		Id:                  bb.NewUUID4(),
+		Time:                startTime,
+		Test:                test,
+		AttackTechniqueId:   test.AttackTechniqueId,
+		AttackTechniqueName: test.AttackTechniqueName,
+		Identity:            GetIdentity(),
+	}
 }
 
 func (result TestResult) GetProcesses() []bb.Process {
@@ -51,3 +113,115 @@ func (result TestResult) GetCommands() []bb.Command {
 	}
 	return commands
 }
+
+// GetExitCode returns the exit code of the test command, or nil if the test command wasn't executed.
+func (result TestResult) GetExitCode() *int {
+	if len(result.ExecutedCommands) == 0 {
+		return nil
+	}
+	exitCode := result.ExecutedCommands[0].ExitCode
+	return &exitCode
+}
+
+// GetDuration returns the amount of time between the test being started and its last command exiting.
+func (result TestResult) GetDuration() time.Duration {
+	end := result.Time
+	for _, dependency := range result.Dependencies {
+		for _, executedCommand := range dependency.ExecutedCommands {
+			end = maxTime(end, executedCommand.EndTime)
+		}
+	}
+	for _, executedCommand := range result.ExecutedCommands {
+		end = maxTime(end, executedCommand.EndTime)
+	}
+	return end.Sub(result.Time)
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// GetExecutables returns the paths to the executables of the processes that were spawned by the test, in the order in
+// which they were first seen.
+func (result TestResult) GetExecutables() []string {
+	var paths []string
+	for _, process := range result.GetProcesses() {
+		if process.Executable != nil {
+			path := process.Executable.Path
+			if path != "" && !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// Succeeded returns true if the test passed.
+func (result TestResult) Succeeded() bool {
+	return result.Status == TestStatusPassed
+}
+
+// getStatus determines the status of a test that was run, along with the reason for the status.
+func (result TestResult) getStatus() (TestStatus, string) {
+	if result.TimedOut {
+		return TestStatusTimedOut, "timed out"
+	}
+	var unmet []string
+	for _, dependency := range result.Dependencies {
+		if !dependency.Met {
+			unmet = append(unmet, strings.TrimSpace(dependency.Dependency.Description))
+		}
+	}
+	if len(unmet) > 0 {
+		return TestStatusFailed, fmt.Sprintf("dependencies not met: %s", strings.Join(unmet, "; "))
+	}
+	// The test command is always executed first, followed by the cleanup command.
+	if len(result.ExecutedCommands) == 0 {
+		return TestStatusErrored, "test command was not executed"
+	}
+	exitCode := *result.GetExitCode()
+	if exitCode != 0 {
+		return TestStatusFailed, fmt.Sprintf("test command exited with status %d", exitCode)
+	}
+	return TestStatusPassed, ""
+}
+
+// TestResultSummary counts test results by status.
+type TestResultSummary struct {
+	Total    int `json:"total" yaml:"total"`
+	Passed   int `json:"passed" yaml:"passed"`
+	Failed   int `json:"failed" yaml:"failed"`
+	Skipped  int `json:"skipped" yaml:"skipped"`
+	Errored  int `json:"errored" yaml:"errored"`
+	TimedOut int `json:"timed_out" yaml:"timed_out"`
+}
+
+func SummarizeTestResults(results []TestResult) TestResultSummary {
+	summary := TestResultSummary{Total: len(results)}
+	for _, result := range results {
+		switch result.Status {
+		case TestStatusPassed:
+			summary.Passed++
+		case TestStatusFailed:
+			summary.Failed++
+		case TestStatusSkipped:
+			summary.Skipped++
+		case TestStatusErrored:
+			summary.Errored++
+		case TestStatusTimedOut:
+			summary.TimedOut++
+		}
+	}
+	return summary
+}
+
+// ExitCode returns 0 if no tests failed, errored, or timed out, and 1 otherwise.
+func (s TestResultSummary) ExitCode() int {
+	if s.Failed+s.Errored+s.TimedOut > 0 {
+		return 1
+	}
+	return 0
+}
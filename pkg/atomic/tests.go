@@ -29,7 +29,7 @@ type Test struct {
 func (t Test) GetReferencesToAtomicsFolder() []string {
 	var references []string
 	for _, command := range t.getCommands() {
-		if strings.Contains(command, "PathToAtomicsDir") {
+		if atomicsFolderPattern.MatchString(command) {
 			references = append(references, command)
 		}
 	}
@@ -87,80 +87,176 @@ func (t Test) MatchesPlatform(platform string) bool {
 	return slices.Contains(t.SupportedPlatforms, platform)
 }
 
-func (t Test) Run(ctx context.Context, atomicsDir string, opts *TestOptions) (*TestResult, error) {
+// Run runs a test, resolving its dependencies first.
+//
+// Once dependency resolution has started, the cleanup command is always run, even if the test fails, times out, or
+// the context is cancelled (e.g. because the user pressed Ctrl-C).
+func (t Test) Run(ctx context.Context, atomicsDir string, opts *TestOptions) (testResult *TestResult, err error) {
 	log.Infof("Executing test: %s", t.GetDisplayName())
 	if opts == nil {
 		opts = NewTestOptions()
 	}
 	now := time.Now()
-	err := t.checkRequirements()
+	err = t.checkRequirements()
 	if err != nil {
 		return nil, err
 	}
-	var executedCommands []bb.ExecutedCommand
+	// Validate and combine input arguments.
+	inputArguments, err := t.ValidateInputArguments(opts.InputArguments)
+	if err != nil {
+		return nil, err
+	}
+	inputArguments = t.combineArgs(inputArguments)
+	if ctx.Err() != nil {
+		return nil, errors.Wrap(ctx.Err(), "test was not started")
+	}
+	testResult = newTestResult(t, now)
 
-	// Combine input arguments.
-	inputArguments := t.combineArgs(opts.InputArguments)
+	// Execute the cleanup command once the test has finished, regardless of how it finished.
+	defer func() {
+		executedCommand, timedOut, cleanupErr := t.cleanup(ctx, atomicsDir, inputArguments, opts)
+		if cleanupErr != nil {
+			log.Errorf("Failed to execute cleanup command (ID: %s): %s", t.AutoGeneratedGuid, cleanupErr)
+		}
+		if err != nil {
+			return
+		}
+		testResult.CleanupTimedOut = timedOut
+		if executedCommand != nil {
+			testResult.ExecutedCommands = append(testResult.ExecutedCommands, *executedCommand)
+		}
+		testResult.Status, testResult.Reason = testResult.getStatus()
+		if ctx.Err() == context.Canceled {
+			testResult.Status, testResult.Reason = TestStatusErrored, "interrupted"
+		}
+	}()
 
 	// Resolve dependencies.
-	var dependencyResolutionResults []DependencyResolutionResult
 	if len(t.Dependencies) > 0 {
 		log.Infof("Test has %d dependencies (ID: %s)", len(t.Dependencies), t.AutoGeneratedGuid)
-		dependencyResolutionResults, err = t.resolveDependencies(ctx, atomicsDir, inputArguments)
+		dependencyCtx, cancel := withTimeout(ctx, opts.GetDependencyTimeout())
+		testResult.Dependencies, err = t.resolveDependencies(dependencyCtx, atomicsDir, inputArguments)
+		cancel()
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to perform dependency resolution")
 		}
 	}
+	for _, result := range testResult.Dependencies {
+		if result.TimedOut {
+			log.Warnf("Timed out while resolving dependencies after %s (ID: %s)", opts.GetDependencyTimeout(), t.AutoGeneratedGuid)
+			testResult.TimedOut = true
+			return testResult, nil
+		}
+	}
 
 	// Execute the primary test command.
 	command, err := prepareCommand(t.Executor.Command, atomicsDir, inputArguments)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to prepare command")
 	}
-	executedCommand, err := bb.ExecuteCommand(ctx, command, t.Executor.Name, nil)
-	if err != nil {
+	testCtx, cancel := withTimeout(ctx, opts.GetTimeout())
+	executedCommand, err := executeCommand(testCtx, command, t.Executor.Name)
+	testResult.TimedOut = isTimeout(testCtx)
+	cancel()
+	if err != nil && !testResult.TimedOut && ctx.Err() == nil {
 		return nil, errors.Wrap(err, "failed to execute command")
 	}
-	executedCommands = append(executedCommands, *executedCommand)
+	err = nil
+	if testResult.TimedOut {
+		log.Warnf("Test command timed out after %s (ID: %s)", opts.GetTimeout(), t.AutoGeneratedGuid)
+	}
+	if executedCommand != nil {
+		testResult.ExecutedCommands = append(testResult.ExecutedCommands, *executedCommand)
+	}
+	return testResult, nil
+}
 
-	// Execute the cleanup command.
-	if t.Executor.CleanupCommand != "" {
-		command, err := prepareCommand(t.Executor.CleanupCommand, atomicsDir, inputArguments)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to prepare cleanup command")
-		}
-		executedCommand, err := bb.ExecuteCommand(ctx, command, t.Executor.Name, nil)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to execute cleanup command")
-		}
-		executedCommands = append(executedCommands, *executedCommand)
+// Cleanup runs the test's cleanup command on its own, using the same input arguments and options as Run.
+func (t Test) Cleanup(ctx context.Context, atomicsDir string, opts *TestOptions) (*TestResult, error) {
+	log.Infof("Cleaning up test: %s", t.GetDisplayName())
+	if opts == nil {
+		opts = NewTestOptions()
 	}
+	now := time.Now()
+	err := t.checkRequirements()
+	if err != nil {
+		return nil, err
+	}
+	if t.Executor.CleanupCommand == "" {
+		return nil, &TestSkippedError{Reason: "test does not have a cleanup command"}
+	}
+	inputArguments, err := t.ValidateInputArguments(opts.InputArguments)
+	if err != nil {
+		return nil, err
+	}
+	inputArguments = t.combineArgs(inputArguments)
 
-	testResult := &TestResult{
-		Id:               bb.NewUUID4(),
-		Time:             now,
-		Test:             t,
-		ExecutedCommands: executedCommands,
-		Dependencies:     dependencyResolutionResults,
+	executedCommand, timedOut, err := t.cleanup(ctx, atomicsDir, inputArguments, opts)
+	if err != nil {
+		return nil, err
+	}
+	testResult := newTestResult(t, now)
+	testResult.Status = TestStatusPassed
+	testResult.CleanupTimedOut = timedOut
+	if executedCommand != nil {
+		testResult.ExecutedCommands = []ExecutedCommand{*executedCommand}
+	}
+	if timedOut {
+		testResult.Status, testResult.Reason = TestStatusTimedOut, "cleanup command timed out"
+	} else if executedCommand != nil && executedCommand.ExitCode != 0 {
+		testResult.Status, testResult.Reason = TestStatusFailed, fmt.Sprintf("cleanup command exited with status %d", executedCommand.ExitCode)
 	}
 	return testResult, nil
 }
 
+// cleanup executes the cleanup command, if the test has one. The cleanup command is executed even if the context has
+// been cancelled, but is still subject to the cleanup timeout.
+func (t Test) cleanup(ctx context.Context, atomicsDir string, inputArguments map[string]interface{}, opts *TestOptions) (*ExecutedCommand, bool, error) {
+	if t.Executor.CleanupCommand == "" {
+		return nil, false, nil
+	}
+	command, err := prepareCommand(t.Executor.CleanupCommand, atomicsDir, inputArguments)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to prepare cleanup command")
+	}
+	cleanupCtx, cancel := withTimeout(context.WithoutCancel(ctx), opts.GetCleanupTimeout())
+	defer cancel()
+	executedCommand, err := executeCommand(cleanupCtx, command, t.Executor.Name)
+	timedOut := isTimeout(cleanupCtx)
+	if timedOut {
+		log.Warnf("Cleanup command timed out after %s (ID: %s)", opts.GetCleanupTimeout(), t.AutoGeneratedGuid)
+	} else if err != nil {
+		return nil, false, errors.Wrap(err, "failed to execute cleanup command")
+	}
+	return executedCommand, timedOut, nil
+}
+
+// RequiresElevation returns true if the test's command or the commands of any of its dependencies must be run with
+// elevated privileges.
+func (t Test) RequiresElevation() bool {
+	if t.Executor.ElevationRequired {
+		return true
+	}
+	return slices.ContainsFunc(t.Dependencies, func(d Dependency) bool {
+		return d.ElevationRequired
+	})
+}
+
 func (t Test) checkRequirements() error {
 	executor := t.Executor
 	if executor.Name == "manual" {
-		return errors.New("manual tests are not supported")
+		return &TestSkippedError{Reason: "manual tests are not supported"}
 	}
 	if !t.MatchesCurrentPlatform() {
-		return errors.New("unsupported platform")
+		return &TestSkippedError{Reason: "unsupported platform"}
 	}
-	if executor.ElevationRequired {
+	if t.RequiresElevation() {
 		elevated, err := bb.IsElevated()
 		if err != nil {
 			return errors.Wrap(err, "failed to check if current process is elevated")
 		}
 		if !elevated {
-			return errors.New("test requires elevation")
+			return &TestSkippedError{Reason: "test requires elevation"}
 		}
 	}
 	return nil
@@ -174,6 +270,9 @@ func (t Test) resolveDependencies(ctx context.Context, atomicsDir string, inputA
 			return nil, err
 		}
 		results = append(results, *result)
+		if result.TimedOut {
+			break
+		}
 	}
 	return results, nil
 }
@@ -197,12 +296,17 @@ type Dependency struct {
 	GetPrereqCommand string             `json:"get_prereq_command" yaml:"get_prereq_command"`
 	ExecutorName     string             `json:"-" yaml:"-"`
 	InputArguments   map[string]ArgSpec `json:"-" yaml:"-"`
+
+	// ElevationRequired indicates that the dependency's commands must be run with elevated privileges, even if the
+	// test's own command doesn't require elevation.
+	ElevationRequired bool `json:"elevation_required,omitempty" yaml:"elevation_required,omitempty"`
 }
 
 type DependencyResolutionResult struct {
-	Dependency       Dependency           `json:"dependency" yaml:"dependency"`
-	ExecutedCommands []bb.ExecutedCommand `json:"executed_commands" yaml:"executed_commands"`
-	Met              bool                 `json:"met" yaml:"met"`
+	Dependency       Dependency        `json:"dependency" yaml:"dependency"`
+	ExecutedCommands []ExecutedCommand `json:"executed_commands" yaml:"executed_commands"`
+	Met              bool              `json:"met" yaml:"met"`
+	TimedOut         bool              `json:"timed_out,omitempty" yaml:"timed_out,omitempty"`
 }
 
 func (d Dependency) combineArgs(inputArguments map[string]interface{}) map[string]interface{} {
@@ -223,19 +327,19 @@ func (d Dependency) CheckDependency(ctx context.Context, atomicsDir string, inpu
 	}
 	result := &DependencyResolutionResult{
 		Dependency:       d,
-		ExecutedCommands: []bb.ExecutedCommand{*executedCommand},
+		ExecutedCommands: []ExecutedCommand{*executedCommand},
 		Met:              met,
 	}
 	return result, nil
 }
 
-func (d Dependency) checkDependency(ctx context.Context, atomicsDir string, inputArguments map[string]interface{}) (*bb.ExecutedCommand, bool, error) {
+func (d Dependency) checkDependency(ctx context.Context, atomicsDir string, inputArguments map[string]interface{}) (*ExecutedCommand, bool, error) {
 	inputArguments = d.combineArgs(inputArguments)
 	command, err := prepareCommand(d.PrereqCommand, atomicsDir, inputArguments)
 	if err != nil {
 		return nil, false, err
 	}
-	executedCommand, err := bb.ExecuteCommand(ctx, command, d.ExecutorName, nil)
+	executedCommand, err := executeCommand(ctx, command, d.ExecutorName)
 	if err != nil {
 		return nil, false, err
 	}
@@ -244,19 +348,21 @@ func (d Dependency) checkDependency(ctx context.Context, atomicsDir string, inpu
 
 func (d Dependency) ResolveDependency(ctx context.Context, atomicsDir string, inputArguments map[string]interface{}) (*DependencyResolutionResult, error) {
 	executedCommands, met, err := d.resolveDependency(ctx, atomicsDir, inputArguments)
-	if err != nil {
+	timedOut := err != nil && isTimeout(ctx)
+	if err != nil && !timedOut {
 		return nil, err
 	}
 	result := &DependencyResolutionResult{
 		Dependency:       d,
 		ExecutedCommands: executedCommands,
 		Met:              met,
+		TimedOut:         timedOut,
 	}
 	return result, nil
 }
 
-func (d Dependency) resolveDependency(ctx context.Context, atomicsDir string, inputArguments map[string]interface{}) ([]bb.ExecutedCommand, bool, error) {
-	var executedCommands []bb.ExecutedCommand
+func (d Dependency) resolveDependency(ctx context.Context, atomicsDir string, inputArguments map[string]interface{}) ([]ExecutedCommand, bool, error) {
+	var executedCommands []ExecutedCommand
 	inputArguments = d.combineArgs(inputArguments)
 
 	// Check if the dependency is met.
@@ -273,13 +379,15 @@ func (d Dependency) resolveDependency(ctx context.Context, atomicsDir string, in
 	// Resolve the dependency.
 	command, err := prepareCommand(d.GetPrereqCommand, atomicsDir, inputArguments)
 	if err != nil {
-		return nil, false, err
+		return executedCommands, false, err
+	}
+	executedCommand, err = executeCommand(ctx, command, d.ExecutorName)
+	if executedCommand != nil {
+		executedCommands = append(executedCommands, *executedCommand)
 	}
-	executedCommand, err = bb.ExecuteCommand(ctx, command, d.ExecutorName, nil)
 	if err != nil {
-		return nil, false, err
+		return executedCommands, false, err
 	}
-	executedCommands = append(executedCommands, *executedCommand)
 
 	// Check if the dependency is met.
 	executedCommand, met, err = d.checkDependency(ctx, atomicsDir, inputArguments)
@@ -293,46 +401,3 @@ func (d Dependency) resolveDependency(ctx context.Context, atomicsDir string, in
 	}
 	return executedCommands, false, nil
 }
-
-func prepareCommand(command, atomicsDir string, inputArguments map[string]interface{}) (string, error) {
-	command, err := patchAtomicsDir(command, atomicsDir)
-	if err != nil {
-		return "", errors.Wrap(err, "failed to patch PathToAtomicsDir")
-	}
-	if inputArguments != nil {
-		command = interpolateArgs(command, inputArguments)
-	}
-	return command, nil
-}
-
-func patchAtomicsDir(command, atomicsDir string) (string, error) {
-	if atomicsDir == "" {
-		return command, nil
-	}
-	atomicsDir = strings.ReplaceAll(atomicsDir, "\\", "\\\\")
-	return strings.ReplaceAll(command, "PathToAtomicsDir", atomicsDir), nil
-}
-
-func interpolateArgs(command string, inputArguments map[string]interface{}) string {
-	for k, v := range inputArguments {
-		command = strings.ReplaceAll(command, fmt.Sprintf("{{%s}}", k), fmt.Sprint(v))
-	}
-	return command
-}
-
-func combineArgs(defaultArguments map[string]ArgSpec, inputArguments map[string]interface{}) map[string]interface{} {
-	m := getArgMap(defaultArguments)
-	for k, v := range inputArguments {
-		m[k] = v
-	}
-	return m
-}
-
-func getArgMap(args map[string]ArgSpec) map[string]interface{} {
-	m := make(map[string]interface{})
-	for k, argspec := range args {
-		v := argspec.DefaultValue
-		m[k] = v
-	}
-	return m
-}
@@ -2,6 +2,7 @@ package atomic
 
 import (
 	"reflect"
+	"strings"
 
 	mapset "github.com/deckarep/golang-set/v2"
 )
@@ -16,13 +17,24 @@ func diffStructFields(a, b interface{}) (mapset.Set[string], mapset.Set[string],
 	return sa, si, sb
 }
 
-// getStructFields returns a list of all struct field names.
+// getStructFields returns a list of all struct field names, as they appear in JSON.
 func getStructFields(i interface{}) mapset.Set[string] {
 	fields := mapset.NewSet[string]()
 	t := reflect.TypeOf(i)
 	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i).Name
-		fields.Add(field)
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				fields = fields.Union(getStructFields(reflect.Zero(field.Type).Interface()))
+				continue
+			}
+			name = field.Name
+		}
+		fields.Add(name)
 	}
 	return fields
 }